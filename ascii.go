@@ -1,10 +1,9 @@
 package qrcode
 
-import (
-	"bytes"
-	"fmt"
-)
+import "bytes"
 
+// ASCII returns the code as text for a terminal, two characters per module
+// colored with ANSI escape sequences, with its quiet zone around it.
 func (c *Code) ASCII() string {
 	var w asciiWriter
 	return w.encode(c)
@@ -15,37 +14,29 @@ type asciiWriter struct {
 }
 
 func (wr *asciiWriter) encode(code *Code) string {
-	wr.WriteString(reset)
-	wr.border(code)
+	w, h := code.dims()
+	q := code.QuietZone
 
-	for x := 0; x < code.Size; x++ {
-		wr.WriteString(white + space)
-		for y := 0; y < code.Size; y++ {
+	wr.WriteString(reset)
+	for y := -q; y < h+q; y++ {
+		prev := ""
+		for x := -q; x < w+q; x++ {
 			c := white
 			if code.IsBlack(x, y) {
 				c = black
 			}
-			wr.WriteString(c + half)
+			if c != prev {
+				wr.WriteString(c)
+				prev = c
+			}
+			wr.WriteString(half)
 		}
-		wr.WriteString(white + space + reset + "\n")
+		wr.WriteString(reset + "\n")
 	}
-
-	wr.border(code)
 	return wr.String()
 }
 
-func (wr *asciiWriter) border(code *Code) {
-	for i := 0; i < 2; i++ {
-		wr.WriteString(white + space)
-		for x := 0; x < code.Size; x++ {
-			fmt.Fprint(wr, half)
-		}
-		wr.WriteString(space + reset + "\n")
-	}
-}
-
 const (
-	space = "    "
 	half  = "  "
 	black = "\033[30;40m"
 	white = "\033[30;47m"
@@ -0,0 +1,47 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASCII(t *testing.T) {
+	for _, q := range []int{0, 2} {
+		c, err := EncodeRect("ascii", M, WithRectVersion(R7x59), WithQuietZone(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, h := c.dims()
+		lines := strings.Split(strings.TrimSuffix(strings.TrimPrefix(c.ASCII(), reset), "\n"), "\n")
+		if len(lines) != h+2*q {
+			t.Fatalf("quiet zone %d: have %d lines want %d", q, len(lines), h+2*q)
+		}
+		for y, line := range lines {
+			// Read the module colors back from the escape sequences.
+			var dark []bool
+			isBlack := false
+			for line != "" {
+				switch {
+				case strings.HasPrefix(line, white):
+					isBlack, line = false, line[len(white):]
+				case strings.HasPrefix(line, black):
+					isBlack, line = true, line[len(black):]
+				case strings.HasPrefix(line, half):
+					dark, line = append(dark, isBlack), line[len(half):]
+				case line == reset:
+					line = ""
+				default:
+					t.Fatalf("quiet zone %d: unexpected %q", q, line)
+				}
+			}
+			if len(dark) != w+2*q {
+				t.Fatalf("quiet zone %d: have %d modules in line %d want %d", q, len(dark), y, w+2*q)
+			}
+			for x, d := range dark {
+				if d != c.IsBlack(x-q, y-q) {
+					t.Fatalf("quiet zone %d: module %d,%d differs", q, x-q, y-q)
+				}
+			}
+		}
+	}
+}
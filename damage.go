@@ -0,0 +1,170 @@
+package qrcode
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A DamageShape is the shape of the damage SimulateDamage does to a code.
+type DamageShape int
+
+const (
+	DamageCenter DamageShape = iota // a square growing from the center of the code
+	DamageNoise                     // modules spread at random over the code
+	DamageCorner                    // a square growing from the bottom right corner
+)
+
+func (s DamageShape) String() string {
+	switch s {
+	case DamageCenter:
+		return "center"
+	case DamageNoise:
+		return "noise"
+	case DamageCorner:
+		return "corner"
+	}
+	return "DamageShape(" + strconv.Itoa(int(s)) + ")"
+}
+
+// DamageOptions are the options of SimulateDamage.
+type DamageOptions struct {
+	Erase            bool  // make damaged modules white instead of flipping them
+	FunctionPatterns bool  // damage the finders, timing, alignment, format and version modules too
+	Seed             int64 // seed of the random noise
+}
+
+// A Tolerance is the largest damage of a shape a code still decodes with.
+type Tolerance struct {
+	Shape   DamageShape
+	Modules int     // number of damaged modules
+	Percent float64 // percentage of all the modules of the code
+}
+
+// SimulateDamage damages the modules of c in growing regions of each shape
+// and decodes the damaged code. It returns the largest damage of each shape
+// the code still decodes with, to its original text, to the module.
+// Function pattern modules are left intact unless opts asks otherwise.
+// The modules are damaged one at a time and the code is decoded after
+// each of them, so the damage stops at the first one the code does not
+// decode with, even if more damage would make it readable again.
+func SimulateDamage(c *Code, opts *DamageOptions) ([]Tolerance, error) {
+	if opts == nil {
+		opts = &DamageOptions{}
+	}
+	r, err := DecodeBitmap(c)
+	if err != nil {
+		return nil, err
+	}
+	w, h := c.dims()
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	if len(p.Pixel) != h || len(p.Pixel[0]) != w {
+		return nil, errors.New("code version does not match its size")
+	}
+
+	mods := damageable(p, opts.FunctionPatterns)
+	var ts []Tolerance
+	for _, shape := range []DamageShape{DamageCenter, DamageNoise, DamageCorner} {
+		order := damageOrder(mods, shape, w, h, opts.Seed)
+		d := *c
+		d.Bitmap = append([]byte(nil), c.Bitmap...)
+		n := 0
+		for ; n < len(order); n++ {
+			// Erasing a white module changes nothing to decode again.
+			if !damage(&d, order[n], opts.Erase) {
+				continue
+			}
+			if dr, err := DecodeBitmap(&d); err != nil || dr.Text != r.Text {
+				break
+			}
+		}
+		ts = append(ts, Tolerance{
+			Shape:   shape,
+			Modules: n,
+			Percent: 100 * float64(n) / float64(w*h),
+		})
+	}
+	return ts, nil
+}
+
+// A module is the position of a module in a code.
+type module struct{ x, y int }
+
+// damageable returns the modules of p that may be damaged: the data
+// modules, and the function pattern ones if functionPatterns is set.
+func damageable(p *coding.Plan, functionPatterns bool) []module {
+	var mods []module
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case coding.Data, coding.Check, coding.Extra, coding.Unused:
+			default:
+				if !functionPatterns {
+					continue
+				}
+			}
+			mods = append(mods, module{x, y})
+		}
+	}
+	return mods
+}
+
+// damageOrder returns mods in the order a damage of the given shape
+// reaches them, in a code of w by h modules.
+func damageOrder(mods []module, shape DamageShape, w, h int, seed int64) []module {
+	order := append([]module(nil), mods...)
+	switch shape {
+	case DamageNoise:
+		rnd := rand.New(rand.NewSource(seed))
+		rnd.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		return order
+	case DamageCenter:
+		// Twice the distance, to keep the center of even sizes exact.
+		dist := func(m module) int {
+			return maxInt(abs(2*m.x-(w-1)), abs(2*m.y-(h-1)))
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return dist(order[i]) < dist(order[j])
+		})
+	case DamageCorner:
+		dist := func(m module) int {
+			return maxInt(w-1-m.x, h-1-m.y)
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return dist(order[i]) < dist(order[j])
+		})
+	}
+	return order
+}
+
+// damage flips the module m of c, or makes it white when erase is set,
+// and reports whether that changed c.
+func damage(c *Code, m module, erase bool) bool {
+	i, bit := m.y*c.Stride+m.x/8, byte(1)<<uint(7-m.x&7)
+	if erase {
+		was := c.Bitmap[i]&bit != 0
+		c.Bitmap[i] &^= bit
+		return was
+	}
+	c.Bitmap[i] ^= bit
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package qrcode
+
+import (
+	"testing"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+func TestSimulateDamage(t *testing.T) {
+	c, err := EncodeWithOptions("how much damage can it take", H, WithVersion(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := SimulateDamage(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 3 {
+		t.Fatalf("have %d tolerances", len(ts))
+	}
+	for _, tol := range ts {
+		if tol.Modules == 0 || tol.Percent <= 0 || tol.Percent >= 50 {
+			t.Errorf("%v: have %+v", tol.Shape, tol)
+		}
+	}
+	// Noise hits many more codewords than a square of the same size.
+	if ts[1].Modules >= ts[0].Modules {
+		t.Errorf("have noise tolerance %d, not below center %d", ts[1].Modules, ts[0].Modules)
+	}
+
+	// A level L code tolerates less damage.
+	low, err := EncodeWithOptions("how much damage can it take", L, WithVersion(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lts, err := SimulateDamage(low, &DamageOptions{Erase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ets, err := SimulateDamage(c, &DamageOptions{Erase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lts[0].Modules >= ets[0].Modules {
+		t.Errorf("have level L tolerance %d, not below level H %d", lts[0].Modules, ets[0].Modules)
+	}
+}
+
+func TestSimulateDamageFunctionPatterns(t *testing.T) {
+	c, err := Encode("function patterns", Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := SimulateDamage(c, &DamageOptions{FunctionPatterns: true, Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tol := range ts {
+		if tol.Modules == 0 {
+			t.Errorf("%v: have no tolerance", tol.Shape)
+		}
+	}
+
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	if n := len(damageable(p, true)); n != c.Size*c.Size {
+		t.Errorf("have %d damageable modules with function patterns want %d", n, c.Size*c.Size)
+	}
+	for _, m := range damageable(p, false) {
+		switch p.Pixel[m.y][m.x].Role() {
+		case coding.Position, coding.Alignment, coding.Timing, coding.Format, coding.PVersion:
+			t.Fatalf("have damageable function pattern module at %d,%d", m.x, m.y)
+		}
+	}
+}
+
+func TestSimulateDamageSteps(t *testing.T) {
+	c, err := EncodeWithOptions("one module at a time", M, WithVersion(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := SimulateDamage(c, &DamageOptions{Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	for _, tol := range ts {
+		order := damageOrder(damageable(p, false), tol.Shape, c.Size, c.Size, 3)
+		d := *c
+		d.Bitmap = append([]byte(nil), c.Bitmap...)
+		// Every damage up to the tolerance decodes, the next one does not.
+		for n := 0; n <= tol.Modules && n < len(order); n++ {
+			if n > 0 {
+				damage(&d, order[n-1], false)
+			}
+			r, err := DecodeBitmap(&d)
+			if err != nil || r.Text != "one module at a time" {
+				t.Fatalf("%v: have no decoding with %d modules, tolerance %d", tol.Shape, n, tol.Modules)
+			}
+		}
+		if tol.Modules < len(order) {
+			damage(&d, order[tol.Modules], false)
+			if r, err := DecodeBitmap(&d); err == nil && r.Text == "one module at a time" {
+				t.Errorf("%v: have decoding past the tolerance %d", tol.Shape, tol.Modules)
+			}
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+	"github.com/cristalhq/qrcode/internal/detect"
+)
+
+// A Result is the content of a decoded code.
+type Result struct {
+	Text    string // decoded text, in the character set it was stored in
+	Level   Level  // error correction level
+	Version int    // QR version, from 1 to 40, 0 for other codes
+	Mask    int    // data mask pattern: 0 to 7 for QR, 0 to 3 for Micro QR, 4 for rMQR
+	Errors  int    // number of codewords repaired by error correction
+
+	MicroVersion MicroVersion // Micro QR version, 0 for other codes
+	RectVersion  RectVersion  // rMQR version, 0 for other codes
+
+	// Corners are the top left, top right, bottom right and bottom left
+	// corners of the code in the image, without the quiet zone.
+	// They are only set by Decode and DecodeAll.
+	Corners [4]image.Point
+
+	// Inverted is set for a light code on a dark background, Mirrored
+	// for a code seen in a mirror, whose modules are read transposed.
+	Inverted, Mirrored bool
+
+	// Index and Total are the position of the code in a Structured Append
+	// sequence, from 0, and the number of codes in it. Total is 0 for a code
+	// that is not part of one. Parity is the sequence parity.
+	Index, Total int
+	Parity       byte
+	dataParity   byte // parity of the data of this code alone
+
+	// Parts are the codes of a Structured Append sequence that DecodeAll
+	// put back together, in order. Text is then the text of the whole
+	// sequence and the other fields describe the first code.
+	Parts []Result
+
+	// Segments, Blocks and Unmasked are what was read, to compare
+	// with what was printed: the segments of the data, the error
+	// correction blocks in the order the code lays them out, and the
+	// modules with the data mask removed.
+	Segments []DecodedSegment
+	Blocks   []Block
+	Unmasked *Code
+}
+
+// A DecodedSegment is a segment of the data of a decoded code.
+// The Data of a ModeECI segment is empty.
+type DecodedSegment struct {
+	Segment
+	Start, End   int // range of the segment data in the text
+	Offset, Bits int // range of the segment, header included, in the data bits
+}
+
+// A Block is an error correction block of a decoded code, as read.
+type Block struct {
+	Data   []byte // data codewords
+	Check  []byte // check codewords
+	Errors int    // number of codewords repaired by error correction
+}
+
+// segmentModes maps QR mode indicators to modes.
+var segmentModes = map[uint]Mode{
+	1: ModeNumeric,
+	2: ModeAlphanumeric,
+	4: ModeByte,
+	8: ModeKanji,
+	7: ModeECI,
+}
+
+// DecodeBitmap decodes the pixel grid of c, a QR, Micro QR
+// or rMQR code, told apart by its size. Damaged pixels are repaired
+// by error correction, as long as there are not too many of them.
+// Only the Bitmap, the size and the Stride of c are used.
+func DecodeBitmap(c *Code) (*Result, error) {
+	w, h := c.dims()
+	if w <= 0 || h <= 0 || c.Stride < (w+7)/8 || len(c.Bitmap) < c.Stride*(h-1)+(w+7)/8 {
+		return nil, errors.New("bitmap too small for the code size")
+	}
+	cc := &coding.Code{
+		Bitmap: c.Bitmap,
+		Size:   w,
+		Width:  w,
+		Height: h,
+		Stride: c.Stride,
+	}
+	d, err := coding.Decode(cc)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(d), nil
+}
+
+func newResult(d *coding.Decoded) *Result {
+	r := &Result{
+		Text:   d.Text,
+		Level:  Level(d.Level),
+		Mask:   int(d.Mask),
+		Errors: d.Errors,
+		Index:  d.Append.Index,
+		Total:  d.Append.Total,
+		Parity: d.Append.Parity,
+
+		dataParity: d.DataParity,
+	}
+	for _, seg := range d.Segments {
+		r.Segments = append(r.Segments, DecodedSegment{
+			Segment: Segment{
+				Mode: segmentModes[seg.Indicator],
+				Data: []byte(d.Text[seg.Start:seg.End]),
+				ECI:  ECI(seg.ECI),
+			},
+			Start:  seg.Start,
+			End:    seg.End,
+			Offset: seg.Offset,
+			Bits:   seg.Bits,
+		})
+	}
+	for _, b := range d.Blocks {
+		n := len(b.Data)
+		r.Blocks = append(r.Blocks, Block{Data: b.Raw[:n], Check: b.Raw[n:], Errors: b.Errors})
+	}
+	r.Unmasked = newCode(d.Unmasked, nil)
+	switch v := d.Version; {
+	case v.Micro():
+		r.MicroVersion = MicroVersion(v-coding.M1) + M1
+	case v.Rect():
+		r.RectVersion = RectVersion(v-coding.R7x43) + R7x43
+	default:
+		r.Version = int(v)
+	}
+	return r
+}
+
+// DecodeFormat returns the error correction level and the mask
+// of the QR code format information closest to word, the 15 bits
+// read from either copy of it in a code, least significant bit first
+// in the order of the specification. Distance is the number of bits
+// that differ: up to 3 wrong bits are corrected, a larger
+// distance means the format information is unreadable.
+func DecodeFormat(word uint32) (level Level, mask, distance int) {
+	l, m, d := coding.DecodeFormat(word)
+	return Level(l), int(m), d
+}
+
+// DecodeVersion returns the version of the QR code version information
+// closest to word, the 18 bits read from either copy of it in a code
+// of version 7 or more. Distance is the number of bits that differ:
+// up to 3 wrong bits are corrected, a larger distance means
+// the version information is unreadable.
+func DecodeVersion(word uint32) (version, distance int) {
+	v, d := coding.DecodeVersion(word)
+	return int(v), d
+}
+
+// Decode finds a QR code in img and decodes it.
+// The image is binarized with a threshold that adapts to the lighting,
+// or a single one if no code is found that way,
+// the code is located by its three position boxes and its modules
+// are sampled through the perspective transform they and the
+// alignment box define, so the code may be scaled, rotated or skewed.
+// When no QR code decodes, Micro QR codes are looked for around each
+// single position box, and rMQR codes around each position box with
+// a sub-position box where one of their versions puts it.
+// Codes that do not decode are read again transposed, as seen in a mirror,
+// and light codes on a dark background are looked for last.
+func Decode(img image.Image) (*Result, error) {
+	err := errNoCode
+	for _, b := range bitmaps(detect.Luminance(img)) {
+		fs := b.Finders()
+		for _, s := range detect.Symbols(fs) {
+			r, serr := decodeSymbol(b, s, img.Bounds().Min)
+			if serr != nil {
+				err = serr
+				continue
+			}
+			return r, nil
+		}
+		for _, f := range singleFinders(fs) {
+			r, serr := decodeSingle(b, f, img.Bounds().Min)
+			if serr != nil {
+				if serr != errNoCode {
+					err = serr
+				}
+				continue
+			}
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+// DecodeAll finds all the QR, Micro QR and rMQR codes in img and decodes
+// them, like Decode. The codes of a Structured Append sequence found
+// together are put back together into a single result.
+// Light codes on a dark background are only looked for
+// when there are no dark ones.
+func DecodeAll(img image.Image) ([]Result, error) {
+	err := errNoCode
+	for _, b := range bitmaps(detect.Luminance(img)) {
+		var rs []Result
+		used := make(map[detect.Point]bool)
+		fs := b.Finders()
+		for _, s := range detect.Symbols(fs) {
+			if used[s.TopLeft.Point] || used[s.TopRight.Point] || used[s.BottomLeft.Point] {
+				continue
+			}
+			r, serr := decodeSymbol(b, s, img.Bounds().Min)
+			if serr != nil {
+				err = serr
+				continue
+			}
+			used[s.TopLeft.Point] = true
+			used[s.TopRight.Point] = true
+			used[s.BottomLeft.Point] = true
+			rs = append(rs, *r)
+		}
+		for _, f := range singleFinders(fs) {
+			if used[f.Point] {
+				continue
+			}
+			r, serr := decodeSingle(b, f, img.Bounds().Min)
+			if serr != nil {
+				if serr != errNoCode {
+					err = serr
+				}
+				continue
+			}
+			used[f.Point] = true
+			rs = append(rs, *r)
+		}
+		if len(rs) > 0 {
+			return joinSequences(rs), nil
+		}
+	}
+	return nil, err
+}
+
+var errNoCode = errors.New("no QR code found")
+
+// maxSingleFinders bounds the number of finders, the most seen ones,
+// tried as the position box of a Micro QR or rMQR code.
+const maxSingleFinders = 16
+
+// singleFinders returns the finders of fs to try
+// as the position box of a Micro QR or rMQR code.
+func singleFinders(fs []detect.Finder) []detect.Finder {
+	if len(fs) > maxSingleFinders {
+		fs = fs[:maxSingleFinders]
+	}
+	return fs
+}
+
+// bitmaps returns the bitmaps of the gray levels lum to look for codes in,
+// in order: one with thresholds that adapt to the lighting, then one with
+// a single threshold for low contrast images, then both inverted
+// for light codes on a dark background.
+func bitmaps(lum []uint8, w, h int) []*detect.Bitmap {
+	bs := []*detect.Bitmap{
+		detect.BinarizeLuminance(lum, w, h),
+		detect.ThresholdLuminance(lum, w, h),
+	}
+	return append(bs, bs[0].Invert(), bs[1].Invert())
+}
+
+// A location is a code decoded from a bitmap.
+type location struct {
+	d        *coding.Decoded
+	t        *detect.Transform // from module coordinates to the ones of the bitmap
+	w, h     int               // size of the code, in modules
+	mirrored bool
+}
+
+// result returns the result of l, found in b, the bitmap
+// of an image whose bounds start at min.
+func (l *location) result(b *detect.Bitmap, min image.Point) *Result {
+	r := newResult(l.d)
+	r.Inverted = b.Inverted
+	r.Mirrored = l.mirrored
+	x, y := float64(l.w), float64(l.h)
+	for i, p := range [4]detect.Point{{X: 0, Y: 0}, {X: x, Y: 0}, {X: x, Y: y}, {X: 0, Y: y}} {
+		q := l.t.Apply(p)
+		r.Corners[i] = image.Pt(int(math.Round(q.X)), int(math.Round(q.Y))).Add(min)
+	}
+	return r
+}
+
+// decodeGrid decodes the code c sampled through t, or else c transposed,
+// for a code seen in a mirror.
+func decodeGrid(c *coding.Code, t *detect.Transform) (*location, error) {
+	d, err := coding.Decode(c)
+	if err == nil {
+		return &location{d: d, t: t, w: c.Width, h: c.Height}, nil
+	}
+	if c.Width != c.Height {
+		return nil, err
+	}
+	if d, terr := coding.Decode(c.Transpose()); terr == nil {
+		return &location{d: d, t: t.Transposed(), w: c.Width, h: c.Height, mirrored: true}, nil
+	}
+	return nil, err
+}
+
+// decodeSymbol samples and decodes the code at s in b, the bitmap
+// of an image whose bounds start at min.
+func decodeSymbol(b *detect.Bitmap, s detect.Symbol, min image.Point) (*Result, error) {
+	l, err := locate(b, s)
+	if err != nil {
+		return nil, err
+	}
+	return l.result(b, min), nil
+}
+
+// decodeSingle samples and decodes the Micro QR or rMQR code with its
+// position box at f in b, the bitmap of an image whose bounds start at min.
+func decodeSingle(b *detect.Bitmap, f detect.Finder, min image.Point) (*Result, error) {
+	err := errNoCode
+	for _, candidates := range []func(detect.Finder) []detect.Candidate{b.Micro, b.Rect} {
+		for _, c := range candidates(f) {
+			l, lerr := decodeGrid(b.Sample(c.T, c.Width, c.Height), c.T)
+			if lerr != nil {
+				err = lerr
+				continue
+			}
+			l.mirrored = l.mirrored || c.Mirrored
+			return l.result(b, min), nil
+		}
+	}
+	return nil, err
+}
+
+// locate samples and decodes the QR code at s in b.
+func locate(b *detect.Bitmap, s detect.Symbol) (*location, error) {
+	err := errNoCode
+	for _, dim := range b.Dimensions(s) {
+		cc, t := b.Grid(s, dim)
+		if dim >= 45 {
+			// The version information, next to the finders,
+			// can be read even if the size estimate is a bit off.
+			// In a mirror, its two copies swap places.
+			v, ok := cc.ReadVersion()
+			if !ok {
+				v, ok = cc.Transpose().ReadVersion()
+			}
+			if ok && 17+4*int(v) != dim {
+				dim = 17 + 4*int(v)
+				cc, t = b.Grid(s, dim)
+			}
+		}
+		l, lerr := decodeGrid(cc, t)
+		if lerr != nil {
+			err = lerr
+			continue
+		}
+		return l, nil
+	}
+	return nil, err
+}
+
+// joinSequences replaces the codes of each complete Structured Append
+// sequence in rs by a single result, where its first code was found.
+func joinSequences(rs []Result) []Result {
+	type key struct {
+		total  int
+		parity byte
+	}
+	seqs := make(map[key][]int) // indexes in rs of the codes, in order
+	for i, r := range rs {
+		if r.Total == 0 {
+			continue
+		}
+		k := key{r.Total, r.Parity}
+		if seqs[k] == nil {
+			seqs[k] = make([]int, r.Total)
+			for j := range seqs[k] {
+				seqs[k][j] = -1
+			}
+		}
+		if r.Index < r.Total && seqs[k][r.Index] < 0 {
+			seqs[k][r.Index] = i
+		}
+	}
+
+	joined := make(map[int]Result) // first found code of a sequence to its result
+	skip := make(map[int]bool)
+	for k, idx := range seqs {
+		var text strings.Builder
+		var parity byte
+		first := len(rs)
+		for _, i := range idx {
+			if i < 0 {
+				first = -1
+				break
+			}
+			text.WriteString(rs[i].Text)
+			parity ^= rs[i].dataParity
+			if i < first {
+				first = i
+			}
+		}
+		if first < 0 || parity != k.parity {
+			continue
+		}
+		seq := rs[idx[0]]
+		seq.Text = text.String()
+		seq.Parts = make([]Result, len(idx))
+		for j, i := range idx {
+			seq.Parts[j] = rs[i]
+			skip[i] = true
+		}
+		joined[first] = seq
+	}
+
+	var out []Result
+	for i, r := range rs {
+		if seq, ok := joined[i]; ok {
+			out = append(out, seq)
+		} else if !skip[i] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,533 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/cristalhq/qrcode/internal/detect"
+)
+
+func TestDecodeBitmap(t *testing.T) {
+	testCases := []struct {
+		text  string
+		level Level
+		opts  []Option
+	}{
+		{"hello, world", L, nil},
+		{"HELLO WORLD 0123456789", H, []Option{WithMask(5)}},
+		{"https://github.com/cristalhq/qrcode", Q, []Option{WithVersion(12)}},
+		{"日本語のテキスト", M, nil},
+	}
+
+	for _, tc := range testCases {
+		c, err := EncodeWithOptions(tc.text, tc.level, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := DecodeBitmap(c)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.text, err)
+		}
+		if r.Text != tc.text || r.Level != tc.level || r.Version != c.Version || r.Mask != c.Mask || r.Errors != 0 {
+			t.Errorf("%q: have %+v", tc.text, r)
+		}
+	}
+}
+
+func TestDecodeBitmapDamaged(t *testing.T) {
+	c, err := Encode("repair me", H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 10; x < 14; x++ {
+		c.Bitmap[12*c.Stride+x/8] ^= 1 << uint(7-x&7)
+	}
+	r, err := DecodeBitmap(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "repair me" || r.Errors == 0 {
+		t.Errorf("have %q with %d errors", r.Text, r.Errors)
+	}
+
+	if _, err := DecodeBitmap(&Code{Bitmap: c.Bitmap, Size: 22, Stride: c.Stride}); err == nil {
+		t.Error("have no error for a bitmap of size 22")
+	}
+	if _, err := DecodeBitmap(&Code{Bitmap: c.Bitmap[:10], Size: c.Size, Stride: c.Stride}); err == nil {
+		t.Error("have no error for a short bitmap")
+	}
+}
+
+func TestDecodeBitmapDetails(t *testing.T) {
+	segs := []Segment{NumericSegment("2024"), ECISegment(UTF8), ByteSegment([]byte("é"))}
+	c, err := EncodeSegments(segs, M, WithVersion(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Damage a data codeword.
+	for x := c.Size - 4; x < c.Size; x++ {
+		c.Bitmap[(c.Size-1)*c.Stride+x/8] ^= 1 << uint(7-x&7)
+	}
+	r, err := DecodeBitmap(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Segments) != len(segs) {
+		t.Fatalf("have %d segments want %d", len(r.Segments), len(segs))
+	}
+	for i, seg := range r.Segments {
+		if seg.Mode != segs[i].Mode || string(seg.Data) != string(segs[i].Data) || seg.ECI != segs[i].ECI ||
+			r.Text[seg.Start:seg.End] != string(seg.Data) {
+			t.Errorf("segment %d: have %+v want %+v", i, seg, segs[i])
+		}
+	}
+
+	if len(r.Blocks) != 1 || r.Blocks[0].Errors == 0 || r.Blocks[0].Errors != r.Errors ||
+		len(r.Blocks[0].Data)+len(r.Blocks[0].Check) != 44 {
+		t.Errorf("have blocks %+v", r.Blocks)
+	}
+
+	// The function patterns are as read, the data pixels differ.
+	u := r.Unmasked
+	if u == nil || u.Size != c.Size {
+		t.Fatalf("have unmasked code %+v", u)
+	}
+	same := true
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if y < 7 && x < 7 && u.IsBlack(x, y) != c.IsBlack(x, y) {
+				t.Fatalf("have finder pixel %d,%d differing", x, y)
+			}
+			same = same && u.IsBlack(x, y) == c.IsBlack(x, y)
+		}
+	}
+	if same {
+		t.Error("have the data pixels still masked")
+	}
+}
+
+func pt(x, y float64) detect.Point {
+	return detect.Point{X: x, Y: y}
+}
+
+// warp returns img seen through the perspective transform that maps
+// its corners onto to, over a gray background, antialiased by sampling
+// four points per pixel.
+func warp(img image.Image, to [4]detect.Point, w, h int) *image.Gray {
+	r := img.Bounds()
+	fw, fh := float64(r.Dx()), float64(r.Dy())
+	t := detect.QuadToQuad(to, [4]detect.Point{pt(0, 0), pt(fw, 0), pt(fw, fh), pt(0, fh)})
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := 0
+			for _, d := range [][2]float64{{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.75}, {0.75, 0.75}} {
+				p := t.Apply(detect.Point{X: float64(x) + d[0], Y: float64(y) + d[1]})
+				px, py := int(math.Floor(p.X)), int(math.Floor(p.Y))
+				if !image.Pt(px, py).In(r) {
+					sum += 160
+					continue
+				}
+				g := color.GrayModel.Convert(img.At(px, py)).(color.Gray)
+				sum += int(g.Y)
+			}
+			out.Pix[y*out.Stride+x] = uint8(sum / 4)
+		}
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+	text := "https://github.com/cristalhq/qrcode"
+	c, err := EncodeWithOptions(text, M, WithVersion(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := c.Image()
+	s := float64(img.Bounds().Dx())
+
+	rotate := func(deg float64, scale, cx, cy float64) [4]detect.Point {
+		a := deg * math.Pi / 180
+		var q [4]detect.Point
+		for i, p := range [4]detect.Point{pt(-s/2, -s/2), pt(s/2, -s/2), pt(s/2, s/2), pt(-s/2, s/2)} {
+			q[i] = detect.Point{
+				X: cx + scale*(p.X*math.Cos(a)-p.Y*math.Sin(a)),
+				Y: cy + scale*(p.X*math.Sin(a)+p.Y*math.Cos(a)),
+			}
+		}
+		return q
+	}
+
+	testCases := []struct {
+		name string
+		img  image.Image
+	}{
+		{"plain", img},
+		{"scaled", warp(img, rotate(0, 0.55, 200, 200), 400, 400)},
+		{"rotated", warp(img, rotate(30, 0.6, 300, 300), 600, 600)},
+		{"upside down", warp(img, rotate(180, 0.7, 250, 250), 500, 500)},
+		{"perspective", warp(img, [4]detect.Point{pt(60, 40), pt(520, 90), pt(480, 560), pt(30, 500)}, 600, 600)},
+	}
+	for _, tc := range testCases {
+		r, err := Decode(tc.img)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if r.Text != text || r.Version != 7 || r.Level != M {
+			t.Errorf("%s: have %+v", tc.name, r)
+		}
+	}
+}
+
+func TestDecodeMicroRect(t *testing.T) {
+	micro, err := EncodeMicro("MICRO 42", M, WithMicroVersion(M3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rect, err := EncodeRect("rectangular", H, WithRectVersion(R13x77))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// turn returns the corners of img turned by deg degrees
+	// and scaled around (cx, cy).
+	turn := func(img image.Image, deg, scale, cx, cy float64) [4]detect.Point {
+		w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+		a := deg * math.Pi / 180
+		var q [4]detect.Point
+		for i, p := range [4]detect.Point{pt(-w/2, -h/2), pt(w/2, -h/2), pt(w/2, h/2), pt(-w/2, h/2)} {
+			q[i] = detect.Point{
+				X: cx + scale*(p.X*math.Cos(a)-p.Y*math.Sin(a)),
+				Y: cy + scale*(p.X*math.Sin(a)+p.Y*math.Cos(a)),
+			}
+		}
+		return q
+	}
+
+	micro.Scale = 6
+	rect.Scale = 5
+	mi, ri := micro.Image(), rect.Image()
+	testCases := []struct {
+		name string
+		img  image.Image
+		text string
+	}{
+		{"micro", mi, "MICRO 42"},
+		{"micro rotated", warp(mi, turn(mi, 25, 0.8, 150, 150), 300, 300), "MICRO 42"},
+		{"micro upside down", warp(mi, turn(mi, 180, 1, 150, 150), 300, 300), "MICRO 42"},
+		{"rect", ri, "rectangular"},
+		{"rect rotated", warp(ri, turn(ri, -8, 1, 250, 200), 500, 400), "rectangular"},
+		{"rect sideways", warp(ri, turn(ri, 90, 1, 200, 250), 400, 500), "rectangular"},
+	}
+	for _, tc := range testCases {
+		r, err := Decode(tc.img)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if r.Text != tc.text || r.Version != 0 {
+			t.Errorf("%s: have %+v", tc.name, r)
+		}
+		if tc.text == "MICRO 42" && (r.MicroVersion != M3 || r.Level != M) {
+			t.Errorf("%s: have version %v level %v", tc.name, r.MicroVersion, r.Level)
+		}
+		if tc.text == "rectangular" && (r.RectVersion != R13x77 || r.Level != H) {
+			t.Errorf("%s: have version %v level %v", tc.name, r.RectVersion, r.Level)
+		}
+	}
+
+	for _, c := range []*Code{micro, rect} {
+		r, err := DecodeBitmap(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.MicroVersion != c.MicroVersion || r.RectVersion != c.RectVersion || r.Mask != c.Mask {
+			t.Errorf("have %+v from bitmap", r)
+		}
+	}
+}
+
+func TestDecodeAllKinds(t *testing.T) {
+	qr, err := Encode("QR", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	micro, err := EncodeMicro("micro", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rect, err := EncodeRect("rMQR", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 900, 400))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	at := []image.Point{{10, 10}, {300, 10}, {10, 250}}
+	for i, c := range []*Code{qr, micro, rect} {
+		c.Scale = 5
+		ci := c.Image()
+		draw.Draw(img, ci.Bounds().Add(at[i]), ci, image.Point{}, draw.Src)
+	}
+
+	rs, err := DecodeAll(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := make(map[string]bool)
+	for _, r := range rs {
+		have[r.Text] = true
+	}
+	if len(rs) != 3 || !have["QR"] || !have["micro"] || !have["rMQR"] {
+		t.Errorf("have %+v", rs)
+	}
+}
+
+// variant returns img as a gray image, inverted and mirrored left to right.
+func variant(img image.Image, invert, mirror bool) *image.Gray {
+	r := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			sx := x
+			if mirror {
+				sx = r.Dx() - 1 - x
+			}
+			g := color.GrayModel.Convert(img.At(r.Min.X+sx, r.Min.Y+y)).(color.Gray)
+			if invert {
+				g.Y = 0xff - g.Y
+			}
+			out.SetGray(x, y, g)
+		}
+	}
+	return out
+}
+
+func TestDecodeInvertedMirrored(t *testing.T) {
+	qr, err := EncodeWithOptions("through the looking glass", Q, WithVersion(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	micro, err := EncodeMicro("MIRROR", L, WithMicroVersion(M2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rect, err := EncodeRect("mirror", M, WithRectVersion(R9x59))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qr.Scale, micro.Scale, rect.Scale = 4, 6, 5
+
+	testCases := []struct {
+		name           string
+		c              *Code
+		invert, mirror bool
+		text           string
+	}{
+		{"qr inverted", qr, true, false, "through the looking glass"},
+		{"qr mirrored", qr, false, true, "through the looking glass"},
+		{"qr inverted and mirrored", qr, true, true, "through the looking glass"},
+		{"micro inverted", micro, true, false, "MIRROR"},
+		{"micro mirrored", micro, false, true, "MIRROR"},
+		{"rect inverted", rect, true, false, "mirror"},
+		{"rect mirrored", rect, false, true, "mirror"},
+	}
+	for _, tc := range testCases {
+		r, err := Decode(variant(tc.c.Image(), tc.invert, tc.mirror))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if r.Text != tc.text || r.Inverted != tc.invert || r.Mirrored != tc.mirror {
+			t.Errorf("%s: have %q inverted %v mirrored %v", tc.name, r.Text, r.Inverted, r.Mirrored)
+		}
+	}
+
+	// The corners of a mirrored code are where its own corners are.
+	img := variant(qr.Image(), false, true)
+	r, err := Decode(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, n := DefaultQuietZone*qr.Scale, qr.Size*qr.Scale
+	want := [4]image.Point{{q + n, q}, {q, q}, {q, q + n}, {q + n, q + n}}
+	for i, p := range r.Corners {
+		if d := p.Sub(want[i]); d.X*d.X+d.Y*d.Y > 4 {
+			t.Errorf("have corner %d at %v want %v", i, p, want[i])
+		}
+	}
+}
+
+func TestDecodeLighting(t *testing.T) {
+	c, err := Encode("uneven light", Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := c.Image()
+	r := src.Bounds()
+	img := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// A gradient from a bright left to a dim right, with noise.
+			l := 255 - 140*x/r.Dx()
+			if src.At(x, y).(color.Gray).Y == 0 {
+				l = 70 - 60*x/r.Dx()
+			}
+			l += (x*7+y*13)%17 - 8
+			img.Set(x, y, color.RGBA{uint8(l), uint8(l), uint8(l), 255})
+		}
+	}
+	res, err := Decode(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "uneven light" {
+		t.Errorf("have %q", res.Text)
+	}
+}
+
+func TestDecodeTransparent(t *testing.T) {
+	c, err := Encode("transparent", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := c.Image()
+	img := image.NewNRGBA(src.Bounds())
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if src.At(x, y).(color.Gray).Y == 0 {
+				img.Set(x, y, color.NRGBA{0x20, 0x20, 0x60, 0xff})
+			}
+		}
+	}
+	r, err := Decode(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "transparent" {
+		t.Errorf("have %q", r.Text)
+	}
+}
+
+func TestDecodeNoCode(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	if _, err := Decode(img); err == nil {
+		t.Error("have no error for a blank image")
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	seq := strings.Repeat("split across codes ", 8)
+	parts, err := EncodeSplit(seq, M, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []*Code
+	for _, text := range []string{"first label", "second label", "third label"} {
+		c, err := Encode(text, L)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, c)
+	}
+	codes = append(codes, parts...)
+
+	// Lay the codes out in a row, offset by a margin, after the parts
+	// of the sequence were put out of order.
+	codes[3], codes[len(codes)-1] = codes[len(codes)-1], codes[3]
+	const scale, margin = 4, 20
+	var rects []image.Rectangle
+	x := margin
+	for _, c := range codes {
+		c.Scale = scale
+		w := c.Image().Bounds().Dx()
+		rects = append(rects, image.Rect(x, margin, x+w, margin+w))
+		x += w
+	}
+	img := image.NewGray(image.Rect(-margin, 0, x+margin, 200))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for i, c := range codes {
+		draw.Draw(img, rects[i], c.Image(), image.Point{}, draw.Src)
+	}
+
+	rs, err := DecodeAll(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"first label": 0, "second label": 1, "third label": 2, seq: 3}
+	if len(rs) != len(want) {
+		t.Fatalf("have %d results want %d", len(rs), len(want))
+	}
+	for _, r := range rs {
+		i, ok := want[r.Text]
+		if !ok {
+			t.Errorf("have unexpected text %q", r.Text)
+			continue
+		}
+		delete(want, r.Text)
+		if i == 3 {
+			if len(r.Parts) != len(parts) || r.Total != len(parts) {
+				t.Errorf("have %d parts of %d want %d", len(r.Parts), r.Total, len(parts))
+			}
+			continue
+		}
+		// The corners are those of the code, inside its quiet zone.
+		q := DefaultQuietZone * scale
+		in := rects[i].Inset(q)
+		corners := [4]image.Point{in.Min, {in.Max.X, in.Min.Y}, in.Max, {in.Min.X, in.Max.Y}}
+		for j, p := range r.Corners {
+			if d := p.Sub(corners[j]); d.X*d.X+d.Y*d.Y > 4 {
+				t.Errorf("%q: have corner %d at %v want %v", r.Text, j, p, corners[j])
+			}
+		}
+	}
+}
+
+func TestDecodeFormat(t *testing.T) {
+	testCases := []struct {
+		word     uint32
+		level    Level
+		mask     int
+		distance int
+	}{
+		{0x77c4, L, 0, 0},
+		{0x5412, M, 0, 0},
+		{0x5412 ^ 0x0101, M, 0, 2},
+		{0x083b ^ 0x4003, H, 7, 3},
+	}
+	for _, tc := range testCases {
+		l, m, d := DecodeFormat(tc.word)
+		if l != tc.level || m != tc.mask || d != tc.distance {
+			t.Errorf("%#x: have %v/%d at %d want %v/%d at %d", tc.word, l, m, d, tc.level, tc.mask, tc.distance)
+		}
+	}
+}
+
+func TestDecodeVersion(t *testing.T) {
+	testCases := []struct {
+		word     uint32
+		version  int
+		distance int
+	}{
+		{0x07c94, 7, 0},
+		{0x07c94 ^ 0x10001, 7, 2},
+		{0x18ec4, 24, 0},
+		{0x28c69 ^ 0x00124, 40, 3},
+	}
+	for _, tc := range testCases {
+		v, d := DecodeVersion(tc.word)
+		if v != tc.version || d != tc.distance {
+			t.Errorf("%#x: have %d at %d want %d at %d", tc.word, v, d, tc.version, tc.distance)
+		}
+	}
+}
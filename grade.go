@@ -0,0 +1,464 @@
+package qrcode
+
+import (
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+	"github.com/cristalhq/qrcode/internal/detect"
+)
+
+// A Rating is a print quality grade, from RatingF, the worst, to RatingA.
+type Rating int
+
+const (
+	RatingF Rating = iota
+	RatingD
+	RatingC
+	RatingB
+	RatingA
+)
+
+func (r Rating) String() string {
+	if r < RatingF || r > RatingA {
+		return "Rating(" + strconv.Itoa(int(r)) + ")"
+	}
+	return string("FDCBA"[r])
+}
+
+// A Report is the print quality of a code, graded in the spirit
+// of ISO/IEC 15415. Reflectances are gray levels, from 0 for black
+// to 1 for white.
+type Report struct {
+	Overall Rating // lowest of the grades below
+
+	Decode                Rating // A if the code decodes, F otherwise
+	SymbolContrast        Rating // difference between the lightest and the darkest reflectances
+	Modulation            Rating // margin of the module reflectances to the threshold
+	FixedPatternDamage    Rating // modules of the wrong color in the finders, timing, alignment and format areas
+	AxialNonuniformity    Rating // difference between the module sizes along the two axes
+	GridNonuniformity     Rating // deviation of the module centers from the grid the finders define
+	UnusedErrorCorrection Rating // error correction left after decoding
+
+	Contrast       float64 // largest minus smallest reflectance
+	DamagedModules int     // largest number of wrong modules in one fixed pattern
+	Axial          float64 // relative difference between the module sizes along the axes
+	Grid           float64 // largest deviation of a module center, in modules
+	UnusedEC       float64 // fraction of error correction left in the worst block
+
+	Result *Result // the decoded code, nil when none could be decoded
+}
+
+// Grade finds a QR code in img, like Decode, and grades its print quality.
+// When no code can be decoded, all the grades are F.
+func Grade(img image.Image) Report {
+	lum, w, h := detect.Luminance(img)
+	for _, b := range bitmaps(lum, w, h) {
+		for _, s := range detect.Symbols(b.Finders()) {
+			l, err := locate(b, s)
+			if err != nil {
+				continue
+			}
+			g := &grader{lum: lum, w: w, h: h, d: l.d, t: l.t, dim: l.w, inverted: b.Inverted}
+			rep := g.report()
+			rep.Result = l.result(b, img.Bounds().Min)
+			return rep
+		}
+	}
+	return Report{}
+}
+
+// grader measures the print quality of the decoded code d, whose modules
+// the transform t maps to the image with gray levels lum.
+type grader struct {
+	lum  []uint8
+	w, h int
+	d    *coding.Decoded
+	t    *detect.Transform
+	dim  int
+
+	inverted bool // the code is light on a dark background
+
+	plan   *coding.Plan
+	want   *coding.Code // the modules of d without damage
+	r      [][]float64  // reflectance of each module
+	lo, hi float64      // smallest and largest reflectances
+}
+
+func (g *grader) report() Report {
+	g.plan = coding.NewPlan(g.d.Version, g.d.Level, g.d.Mask)
+	g.want = g.plan.LayoutInto(nil, g.d.Codewords())
+	g.reflectances()
+
+	rep := Report{Decode: RatingA}
+	rep.Contrast = g.hi - g.lo
+	rep.SymbolContrast = rate(rep.Contrast, 0.70, 0.55, 0.40, 0.20)
+	rep.UnusedEC = g.unusedEC()
+	rep.UnusedErrorCorrection = rate(rep.UnusedEC, 0.62, 0.50, 0.37, 0.25)
+	rep.Modulation = g.modulation()
+	rep.DamagedModules = g.damage()
+	rep.FixedPatternDamage = rate(-float64(rep.DamagedModules), 0, -1, -2, -3)
+	rep.Axial = g.axial()
+	rep.AxialNonuniformity = rate(-rep.Axial, -0.06, -0.08, -0.10, -0.12)
+	rep.Grid = g.grid()
+	rep.GridNonuniformity = rate(-rep.Grid, -0.38, -0.50, -0.63, -0.75)
+
+	rep.Overall = rep.Decode
+	for _, r := range []Rating{
+		rep.SymbolContrast, rep.Modulation, rep.FixedPatternDamage,
+		rep.AxialNonuniformity, rep.GridNonuniformity, rep.UnusedErrorCorrection,
+	} {
+		if r < rep.Overall {
+			rep.Overall = r
+		}
+	}
+	return rep
+}
+
+// rate returns the grade of v, A if it is at least a, B if it is at least b,
+// and so on. Measures where lower is better are rated by their opposite.
+func rate(v, a, b, c, d float64) Rating {
+	switch {
+	case v >= a:
+		return RatingA
+	case v >= b:
+		return RatingB
+	case v >= c:
+		return RatingC
+	case v >= d:
+		return RatingD
+	}
+	return RatingF
+}
+
+// reflectance returns the average gray level of the image around
+// the point (x, y) in module coordinates, in a square aperture
+// of half a module, and whether that area is in the image.
+func (g *grader) reflectance(x, y float64) (float64, bool) {
+	sum, n := 0, 0
+	for _, dy := range []float64{-0.25, 0, 0.25} {
+		for _, dx := range []float64{-0.25, 0, 0.25} {
+			p := g.t.Apply(detect.Point{X: x + dx, Y: y + dy})
+			px, py := int(math.Floor(p.X)), int(math.Floor(p.Y))
+			if px < 0 || py < 0 || px >= g.w || py >= g.h {
+				continue
+			}
+			sum += int(g.lum[py*g.w+px])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return float64(sum) / float64(n) / 255, true
+}
+
+// reflectances measures the modules of the code, and the range
+// of reflectances of the code and of its quiet zone.
+func (g *grader) reflectances() {
+	g.lo, g.hi = 1, 0
+	g.r = make([][]float64, g.dim)
+	for y := -2; y < g.dim+2; y++ {
+		if 0 <= y && y < g.dim {
+			g.r[y] = make([]float64, g.dim)
+		}
+		for x := -2; x < g.dim+2; x++ {
+			r, ok := g.reflectance(float64(x)+0.5, float64(y)+0.5)
+			if !ok {
+				continue
+			}
+			g.lo, g.hi = math.Min(g.lo, r), math.Max(g.hi, r)
+			if 0 <= x && x < g.dim && 0 <= y && y < g.dim {
+				g.r[y][x] = r
+			}
+		}
+	}
+}
+
+// dark reports whether the module at (x, y) reads as dark,
+// or as light in an inverted code.
+func (g *grader) dark(x, y int) bool {
+	return (g.r[y][x] < (g.lo+g.hi)/2) != g.inverted
+}
+
+// moduleRating returns the modulation grade of the module at (x, y):
+// how far its reflectance is from the threshold, relative to the contrast.
+// A module of the wrong color is rated F.
+func (g *grader) moduleRating(x, y int) Rating {
+	if g.dark(x, y) != g.want.Black(x, y) || g.hi <= g.lo {
+		return RatingF
+	}
+	mod := 2 * math.Abs(g.r[y][x]-(g.lo+g.hi)/2) / (g.hi - g.lo)
+	return rate(mod, 0.50, 0.40, 0.30, 0.20)
+}
+
+// modulation returns the modulation grade of the code. A codeword is
+// rated by its worst module. For each grade, the codewords rated lower
+// are taken as erasures, and the grade is kept if the error correction
+// left after them rates as high: the best such grade is the modulation grade.
+func (g *grader) modulation() Rating {
+	ec := g.correcting()
+	words := make([]Rating, len(g.d.Codewords()))
+	for i := range words {
+		words[i] = RatingA
+	}
+	for y, row := range g.plan.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case coding.Data, coding.Check:
+				k := pix.Offset() / 8
+				if r := g.moduleRating(x, y); r < words[k] {
+					words[k] = r
+				}
+			}
+		}
+	}
+
+	for level := RatingA; level > RatingF; level-- {
+		erasures := make([]int, len(g.d.Blocks))
+		for k, r := range words {
+			if r < level {
+				erasures[g.d.BlockOf(k)]++
+			}
+		}
+		uec := 1.0
+		for _, n := range erasures {
+			uec = math.Min(uec, 1-float64(n)/ec)
+		}
+		if rate(uec, 0.62, 0.50, 0.37, 0.25) >= level {
+			return level
+		}
+	}
+	return RatingF
+}
+
+// unusedEC returns the fraction of error correction left after decoding,
+// in the block that used the most. An error uses two check bytes.
+func (g *grader) unusedEC() float64 {
+	ec := g.correcting()
+	uec := 1.0
+	for _, b := range g.d.Blocks {
+		uec = math.Min(uec, 1-2*float64(b.Errors)/ec)
+	}
+	return math.Max(uec, 0)
+}
+
+// correcting returns the number of check bytes of a block that correct
+// errors, without the misdecode protection ones, as a float.
+// Those of QR codes are even, twice the errors a block can correct.
+func (g *grader) correcting() float64 {
+	return float64(2 * g.d.Version.Correctable(g.d.Level))
+}
+
+// damage returns the largest number of modules of the wrong color
+// in a fixed pattern: a finder with its separator, a timing pattern,
+// an alignment box, or a copy of the format or version information.
+func (g *grader) damage() int {
+	seen := make([][]bool, g.dim)
+	for y := range seen {
+		seen[y] = make([]bool, g.dim)
+	}
+	worst := 0
+	for y, row := range g.plan.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case coding.Position, coding.Alignment, coding.Timing, coding.Format, coding.PVersion:
+				if !seen[y][x] {
+					if n := g.patternDamage(x, y, pix.Role(), seen); n > worst {
+						worst = n
+					}
+				}
+			}
+		}
+	}
+	return worst
+}
+
+// patternDamage returns the number of modules of the wrong color in the
+// pattern with role at (x, y): the modules with that role next to each other.
+func (g *grader) patternDamage(x, y int, role coding.PixelRole, seen [][]bool) int {
+	n := 0
+	stack := []image.Point{{X: x, Y: y}}
+	seen[y][x] = true
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if g.dark(p.X, p.Y) != g.want.Black(p.X, p.Y) {
+			n++
+		}
+		for _, d := range []image.Point{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}} {
+			q := p.Add(d)
+			if q.X < 0 || q.Y < 0 || q.X >= g.dim || q.Y >= g.dim || seen[q.Y][q.X] {
+				continue
+			}
+			if g.plan.Pixel[q.Y][q.X].Role() == role {
+				seen[q.Y][q.X] = true
+				stack = append(stack, q)
+			}
+		}
+	}
+	return n
+}
+
+// darkAt reports whether the image is dark at the point p
+// in module coordinates, or light in an inverted code.
+func (g *grader) darkAt(p detect.Point) bool {
+	q := g.t.Apply(p)
+	x, y := int(math.Floor(q.X)), int(math.Floor(q.Y))
+	if x < 0 || y < 0 || x >= g.w || y >= g.h {
+		return g.inverted
+	}
+	return (float64(g.lum[y*g.w+x])/255 < (g.lo+g.hi)/2) != g.inverted
+}
+
+// edgeStep is the step of the scans for the edges of modules, in modules.
+const edgeStep = 1.0 / 32
+
+// edgeCenter measures where the run of modules of the color of want
+// through p is centered along the direction d, a unit vector in module
+// coordinates. It scans up to max modules on each side of p for the edges
+// of the run, and returns the offset of its center from p, in modules.
+func (g *grader) edgeCenter(p, d detect.Point, want bool, max float64) (float64, bool) {
+	if g.darkAt(p) != want {
+		return 0, false
+	}
+	var ends [2]float64
+	for i, sign := range []float64{-1, 1} {
+		t := edgeStep
+		for ; t <= max; t += edgeStep {
+			if g.darkAt(detect.Point{X: p.X + sign*t*d.X, Y: p.Y + sign*t*d.Y}) != want {
+				break
+			}
+		}
+		if t > max {
+			return 0, false
+		}
+		ends[i] = sign * (t - edgeStep/2)
+	}
+	return (ends[0] + ends[1]) / 2, true
+}
+
+// patternCenter measures the center of the dark square of a finder or
+// alignment pattern, of a side of size modules, expected at p.
+func (g *grader) patternCenter(p detect.Point, size float64) (detect.Point, bool) {
+	ox, ok := g.edgeCenter(p, detect.Point{X: 1}, true, size)
+	if !ok {
+		return p, false
+	}
+	p.X += ox
+	oy, ok := g.edgeCenter(p, detect.Point{Y: 1}, true, size)
+	if !ok {
+		return p, false
+	}
+	p.Y += oy
+	// Once more across, through the center found along the other axis.
+	if ox, ok = g.edgeCenter(p, detect.Point{X: 1}, true, size); ok {
+		p.X += ox
+	}
+	return p, true
+}
+
+// finderCenters returns the centers of the top left, top right and
+// bottom left finders measured in the image, in image coordinates.
+func (g *grader) finderCenters() ([3]detect.Point, bool) {
+	n := float64(g.dim)
+	var cs [3]detect.Point
+	for i, p := range []detect.Point{{X: 3.5, Y: 3.5}, {X: n - 3.5, Y: 3.5}, {X: 3.5, Y: n - 3.5}} {
+		c, ok := g.patternCenter(p, 3)
+		if !ok {
+			return cs, false
+		}
+		cs[i] = g.t.Apply(c)
+	}
+	return cs, true
+}
+
+// axial returns the axial nonuniformity of the code: the difference
+// between the module sizes along the two axes, relative to their mean.
+// The sizes are the distances between the finder centers measured
+// in the image over the number of modules between them.
+func (g *grader) axial() float64 {
+	fs, ok := g.finderCenters()
+	if !ok {
+		return 1
+	}
+	x := dist(fs[0], fs[1]) / float64(g.dim-7)
+	y := dist(fs[0], fs[2]) / float64(g.dim-7)
+	return math.Abs(x-y) / ((x + y) / 2)
+}
+
+// grid returns the grid nonuniformity of the code: the largest distance,
+// in modules, between the center of a finder, alignment or timing module
+// measured in the image and where the regular grid through the measured
+// centers of the finders puts it. Timing modules are only measured
+// along their pattern.
+func (g *grader) grid() float64 {
+	fs, ok := g.finderCenters()
+	if !ok {
+		return 1
+	}
+	n := float64(g.dim - 7)
+	u := detect.Point{X: (fs[1].X - fs[0].X) / n, Y: (fs[1].Y - fs[0].Y) / n}
+	v := detect.Point{X: (fs[2].X - fs[0].X) / n, Y: (fs[2].Y - fs[0].Y) / n}
+	module := (math.Hypot(u.X, u.Y) + math.Hypot(v.X, v.Y)) / 2
+	ideal := func(p detect.Point) detect.Point {
+		x, y := p.X-3.5, p.Y-3.5
+		return detect.Point{X: fs[0].X + x*u.X + y*v.X, Y: fs[0].Y + x*u.Y + y*v.Y}
+	}
+
+	worst := 0.0
+	deviation := func(measured, want detect.Point, along *detect.Point) {
+		m, i := g.t.Apply(measured), ideal(want)
+		dx, dy := m.X-i.X, m.Y-i.Y
+		d := math.Hypot(dx, dy)
+		if along != nil {
+			l := math.Hypot(along.X, along.Y)
+			d = math.Abs(dx*along.X+dy*along.Y) / l
+		}
+		worst = math.Max(worst, d/module)
+	}
+	for y, row := range g.plan.Pixel {
+		for x, pix := range row {
+			p := detect.Point{X: float64(x) + 0.5, Y: float64(y) + 0.5}
+			switch pix.Role() {
+			case coding.Alignment:
+				if !g.alignmentCenter(x, y) {
+					continue
+				}
+				if c, ok := g.patternCenter(p, 1); ok {
+					deviation(c, p, nil)
+				}
+			case coding.Timing:
+				d, along := detect.Point{X: 1}, u
+				if x == 6 {
+					d, along = detect.Point{Y: 1}, v
+				}
+				if o, ok := g.edgeCenter(p, d, g.want.Black(x, y), 1); ok {
+					deviation(detect.Point{X: p.X + o*d.X, Y: p.Y + o*d.Y}, p, &along)
+				}
+			}
+		}
+	}
+	return worst
+}
+
+// alignmentCenter reports whether (x, y) is the center of an alignment box.
+func (g *grader) alignmentCenter(x, y int) bool {
+	if !g.want.Black(x, y) || x < 1 || y < 1 || x+1 >= g.dim || y+1 >= g.dim {
+		return false
+	}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			pix := g.plan.Pixel[y+dy][x+dx]
+			if (dx != 0 || dy != 0) && (pix.Role() != coding.Alignment || g.want.Black(x+dx, y+dy)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dist(p, q detect.Point) float64 {
+	return math.Hypot(p.X-q.X, p.Y-q.Y)
+}
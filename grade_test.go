@@ -0,0 +1,185 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// gradeImage returns an image of c with the given gray levels
+// for its dark and light modules, after calling damage for each
+// of its modules to choose whether to flip it.
+func gradeImage(c *Code, dark, light uint8, damage func(x, y int) bool) *image.Gray {
+	src := c.Image()
+	r := src.Bounds()
+	img := image.NewGray(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			mx, my := x/c.Scale-c.QuietZone, y/c.Scale-c.QuietZone
+			black := src.At(x, y).(color.Gray).Y == 0
+			if damage != nil && damage(mx, my) {
+				black = !black
+			}
+			l := light
+			if black {
+				l = dark
+			}
+			img.Pix[y*img.Stride+x] = l
+		}
+	}
+	return img
+}
+
+func TestGrade(t *testing.T) {
+	c, err := EncodeWithOptions("https://github.com/cristalhq/qrcode", M, WithVersion(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep := Grade(gradeImage(c, 0, 255, nil))
+	if rep.Overall != RatingA || rep.Result == nil || rep.Result.Text != "https://github.com/cristalhq/qrcode" {
+		t.Fatalf("clean code: have %+v", rep)
+	}
+	if rep.Contrast != 1 || rep.DamagedModules != 0 || rep.UnusedEC != 1 || rep.Axial > 0.01 || rep.Grid > 0.1 {
+		t.Errorf("clean code: have %+v", rep)
+	}
+
+	rep = Grade(gradeImage(c, 255, 0, nil))
+	if rep.Overall != RatingA || rep.Result == nil || !rep.Result.Inverted {
+		t.Errorf("inverted code: have %+v", rep)
+	}
+
+	rep = Grade(gradeImage(c, 120, 200, nil))
+	if rep.SymbolContrast != RatingD || rep.Overall != RatingD {
+		t.Errorf("low contrast: have %v overall %v", rep.SymbolContrast, rep.Overall)
+	}
+
+	// Two modules of the top left finder.
+	rep = Grade(gradeImage(c, 0, 255, func(x, y int) bool {
+		return x == 0 && y == 1 || x == 5 && y == 6
+	}))
+	if rep.DamagedModules != 2 || rep.FixedPatternDamage != RatingC {
+		t.Errorf("damaged finder: have %d modules, %v", rep.DamagedModules, rep.FixedPatternDamage)
+	}
+
+	// A square of data modules.
+	rep = Grade(gradeImage(c, 0, 255, func(x, y int) bool {
+		return 20 <= x && x < 26 && 12 <= y && y < 18
+	}))
+	if rep.Result == nil || rep.UnusedErrorCorrection == RatingA || rep.FixedPatternDamage != RatingA {
+		t.Errorf("damaged data: have %+v", rep)
+	}
+}
+
+func TestGradeModulation(t *testing.T) {
+	c, err := Encode("faded print", H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fade the dark modules of the lower part close to the threshold.
+	src := gradeImage(c, 0, 255, nil)
+	img := image.NewGray(src.Rect)
+	copy(img.Pix, src.Pix)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			mx, my := x/c.Scale-c.QuietZone, y/c.Scale-c.QuietZone
+			if 0 <= mx && mx < c.Size && 9 <= my && my < c.Size && img.Pix[y*img.Stride+x] == 0 {
+				img.Pix[y*img.Stride+x] = 100
+			}
+		}
+	}
+	rep := Grade(img)
+	if rep.Result == nil || rep.Modulation != RatingD {
+		t.Errorf("have modulation %v", rep.Modulation)
+	}
+	if rep.UnusedErrorCorrection != RatingA {
+		t.Errorf("have unused error correction %v", rep.UnusedErrorCorrection)
+	}
+}
+
+func TestGradeMisdecode(t *testing.T) {
+	// A 1-L code corrects 2 errors: 4 of its 7 check bytes correct them,
+	// the other 3 protect against misdecoding.
+	c, err := EncodeWithOptions("misdecode", L, WithVersion(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := coding.NewPlan(coding.Version(c.Version), coding.Level(c.Level), coding.Mask(c.Mask))
+	flip := make(map[image.Point]bool)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case coding.Data, coding.Check:
+				if k := pix.Offset() / 8; pix.Offset()%8 == 0 && k < 2 {
+					flip[image.Pt(x, y)] = true
+				}
+			}
+		}
+	}
+	rep := Grade(gradeImage(c, 0, 255, func(x, y int) bool { return flip[image.Pt(x, y)] }))
+	if rep.Result == nil || rep.UnusedEC != 0 || rep.UnusedErrorCorrection != RatingF {
+		t.Errorf("have unused error correction %v (%v)", rep.UnusedErrorCorrection, rep.UnusedEC)
+	}
+}
+
+func TestGradeAxial(t *testing.T) {
+	c, err := Encode("stretched", Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := gradeImage(c, 0, 255, nil)
+	r := src.Bounds()
+	// Stretch the image by 20% horizontally.
+	img := image.NewGray(image.Rect(0, 0, r.Dx()*6/5, r.Dy()))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			img.Pix[y*img.Stride+x] = src.Pix[y*src.Stride+x*5/6]
+		}
+	}
+	rep := Grade(img)
+	if rep.Result == nil || rep.AxialNonuniformity != RatingF || rep.Axial < 0.15 {
+		t.Errorf("have axial nonuniformity %v (%v)", rep.AxialNonuniformity, rep.Axial)
+	}
+}
+
+func TestGradeNoCode(t *testing.T) {
+	rep := Grade(image.NewGray(image.Rect(0, 0, 50, 50)))
+	if rep.Overall != RatingF || rep.Decode != RatingF || rep.Result != nil {
+		t.Errorf("have %+v", rep)
+	}
+	if RatingA.String() != "A" || RatingF.String() != "F" {
+		t.Errorf("have %v and %v", RatingA, RatingF)
+	}
+}
+
+func TestGradeGrid(t *testing.T) {
+	c, err := EncodeWithOptions("bulging print", Q, WithVersion(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := gradeImage(c, 0, 255, nil)
+	r := src.Bounds()
+	// Push the middle columns of the code right, by up to 0.6 modules,
+	// keeping the finders in place.
+	q, n := float64(c.QuietZone*c.Scale), float64(c.Size*c.Scale)
+	img := image.NewGray(r)
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			sx := float64(x)
+			if u := (sx - q) / n; 0 < u && u < 1 {
+				sx -= 0.6 * float64(c.Scale) * math.Sin(math.Pi*u)
+			}
+			img.Pix[y*img.Stride+x] = src.Pix[y*src.Stride+int(sx)]
+		}
+	}
+	rep := Grade(img)
+	if rep.Result == nil || rep.Grid < 0.38 || rep.Grid > 0.6 || rep.GridNonuniformity >= RatingA {
+		t.Errorf("have grid nonuniformity %v (%v)", rep.GridNonuniformity, rep.Grid)
+	}
+	if rep.Axial > 0.02 {
+		t.Errorf("have axial nonuniformity %v", rep.Axial)
+	}
+}
@@ -0,0 +1,291 @@
+package qrcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A GS1Element is a GS1 Application Identifier with its data.
+type GS1Element struct {
+	AI    string // application identifier, like "01" for GTIN
+	Value string // data of the element
+}
+
+// EncodeGS1 returns an encoding of the GS1 element strings at the given
+// error correction level configured with opts. It checks the format
+// of every element and the check digit of identification keys like
+// GTIN and SSCC, and marks the code as GS1 with FNC1 in the first position.
+func EncodeGS1(elems []GS1Element, level Level, opts ...Option) (*Code, error) {
+	o, err := newOptions(opts, symbolQR)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gs1Data(elems)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := coding.EncodeGS1(nil, data, coding.Level(level), &o.coding)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, o), nil
+}
+
+// gs1Data returns the element string of elems,
+// with GS after every variable-length element except the last one.
+func gs1Data(elems []GS1Element) (string, error) {
+	if len(elems) == 0 {
+		return "", fmt.Errorf("gs1: no elements")
+	}
+
+	var b strings.Builder
+	for i, e := range elems {
+		ai, ok := lookupAI(e.AI)
+		if !ok {
+			return "", fmt.Errorf("gs1: unknown application identifier %q", e.AI)
+		}
+		if err := ai.check(e.Value); err != nil {
+			return "", fmt.Errorf("gs1: AI (%s): %w", e.AI, err)
+		}
+		b.WriteString(e.AI)
+		b.WriteString(e.Value)
+		if i < len(elems)-1 && !predefinedLength(e.AI) {
+			b.WriteByte(coding.GS)
+		}
+	}
+	return b.String(), nil
+}
+
+// predefinedLength reports whether elements of the AI have a length
+// known to every reader, so they need no GS separator.
+// See GS1 General Specifications, figure 7.8.4-2.
+func predefinedLength(ai string) bool {
+	switch ai[:2] {
+	case "00", "01", "02", "03", "04",
+		"11", "12", "13", "14", "15", "16", "17", "18", "19", "20",
+		"31", "32", "33", "34", "35", "36", "41":
+		return true
+	default:
+		return false
+	}
+}
+
+// A gs1AI describes the data format of an application identifier.
+type gs1AI struct {
+	format     string // components like N14, X..20 or N3+N..15
+	checkDigit bool   // the first component ends with a check digit
+}
+
+// lookupAI returns the format of the AI. AIs with the last digit
+// giving a decimal point position, like 310n, are listed with n.
+func lookupAI(ai string) (gs1AI, bool) {
+	if len(ai) < 2 || len(ai) > 4 {
+		return gs1AI{}, false
+	}
+	for _, c := range ai {
+		if c < '0' || '9' < c {
+			return gs1AI{}, false
+		}
+	}
+	if f, ok := gs1AIs[ai]; ok {
+		return f, true
+	}
+	if len(ai) == 4 {
+		f, ok := gs1AIs[ai[:3]+"n"]
+		return f, ok
+	}
+	return gs1AI{}, false
+}
+
+// check reports whether value matches the format of the AI.
+func (ai gs1AI) check(value string) error {
+	rest := value
+	for i, part := range strings.Split(ai.format, "+") {
+		numeric := part[0] == 'N'
+		variable := strings.HasPrefix(part[1:], "..")
+		max, _ := strconv.Atoi(strings.TrimPrefix(part[1:], ".."))
+
+		n := max
+		if variable && len(rest) < max {
+			n = len(rest)
+		}
+		// Only the first component of a variable-length element is required.
+		if len(rest) < n || n == 0 && i == 0 {
+			return fmt.Errorf("value %q is too short", value)
+		}
+		v := rest[:n]
+		rest = rest[n:]
+
+		for _, c := range v {
+			if numeric && (c < '0' || '9' < c) {
+				return fmt.Errorf("value %q must be numeric", value)
+			}
+			if !strings.ContainsRune(gs1Charset, c) {
+				return fmt.Errorf("value %q has invalid character %q", value, c)
+			}
+		}
+		if i == 0 && ai.checkDigit && !validCheckDigit(v) {
+			return fmt.Errorf("value %q has invalid check digit", value)
+		}
+	}
+	if rest != "" {
+		return fmt.Errorf("value %q is too long", value)
+	}
+	return nil
+}
+
+// validCheckDigit reports whether the last digit of s
+// is the GS1 modulo 10 check digit of the others.
+func validCheckDigit(s string) bool {
+	sum := 0
+	for i := len(s) - 2; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if (len(s)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return int(s[len(s)-1]-'0') == (10-sum%10)%10
+}
+
+// gs1Charset is the GS1 AI encodable character set 82.
+const gs1Charset = `!"%&'()*+,-./0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz`
+
+// gs1AIs lists the formats of the application identifiers,
+// from GS1 General Specifications, section 3.2.
+var gs1AIs = map[string]gs1AI{
+	"00":   {"N18", true},       // SSCC
+	"01":   {"N14", true},       // GTIN
+	"02":   {"N14", true},       // CONTENT
+	"03":   {"N14", true},       // MTO GTIN
+	"10":   {"X..20", false},    // BATCH/LOT
+	"11":   {"N6", false},       // PROD DATE
+	"12":   {"N6", false},       // DUE DATE
+	"13":   {"N6", false},       // PACK DATE
+	"15":   {"N6", false},       // BEST BEFORE or BEST BY
+	"16":   {"N6", false},       // SELL BY
+	"17":   {"N6", false},       // USE BY or EXPIRY
+	"20":   {"N2", false},       // VARIANT
+	"21":   {"X..20", false},    // SERIAL
+	"22":   {"X..20", false},    // CPV
+	"235":  {"X..28", false},    // TPX
+	"240":  {"X..30", false},    // ADDITIONAL ID
+	"241":  {"X..30", false},    // CUST. PART No.
+	"242":  {"N..6", false},     // MTO VARIANT
+	"243":  {"X..20", false},    // PCN
+	"250":  {"X..30", false},    // SECONDARY SERIAL
+	"251":  {"X..30", false},    // REF. TO SOURCE
+	"253":  {"N13+X..17", true}, // GDTI
+	"254":  {"X..20", false},    // GLN EXTENSION COMPONENT
+	"255":  {"N13+N..12", true}, // GCN
+	"30":   {"N..8", false},     // VAR. COUNT
+	"310n": {"N6", false},       // NET WEIGHT (kg)
+	"311n": {"N6", false},       // LENGTH (m)
+	"312n": {"N6", false},       // WIDTH (m)
+	"313n": {"N6", false},       // HEIGHT (m)
+	"314n": {"N6", false},       // AREA (m²)
+	"315n": {"N6", false},       // NET VOLUME (l)
+	"316n": {"N6", false},       // NET VOLUME (m³)
+	"320n": {"N6", false},       // NET WEIGHT (lb)
+	"321n": {"N6", false},       // LENGTH (in)
+	"322n": {"N6", false},       // LENGTH (ft)
+	"323n": {"N6", false},       // LENGTH (yd)
+	"324n": {"N6", false},       // WIDTH (in)
+	"325n": {"N6", false},       // WIDTH (ft)
+	"326n": {"N6", false},       // WIDTH (yd)
+	"327n": {"N6", false},       // HEIGHT (in)
+	"328n": {"N6", false},       // HEIGHT (ft)
+	"329n": {"N6", false},       // HEIGHT (yd)
+	"330n": {"N6", false},       // GROSS WEIGHT (kg)
+	"331n": {"N6", false},       // LENGTH (m), log
+	"332n": {"N6", false},       // WIDTH (m), log
+	"333n": {"N6", false},       // HEIGHT (m), log
+	"334n": {"N6", false},       // AREA (m²), log
+	"335n": {"N6", false},       // VOLUME (l), log
+	"336n": {"N6", false},       // VOLUME (m³), log
+	"337n": {"N6", false},       // KG PER m²
+	"340n": {"N6", false},       // GROSS WEIGHT (lb)
+	"350n": {"N6", false},       // AREA (in²)
+	"351n": {"N6", false},       // AREA (ft²)
+	"352n": {"N6", false},       // AREA (yd²)
+	"356n": {"N6", false},       // NET WEIGHT (t oz)
+	"357n": {"N6", false},       // NET VOLUME (oz)
+	"360n": {"N6", false},       // NET VOLUME (qt)
+	"361n": {"N6", false},       // NET VOLUME (gal)
+	"364n": {"N6", false},       // VOLUME (in³)
+	"365n": {"N6", false},       // VOLUME (ft³)
+	"366n": {"N6", false},       // VOLUME (yd³)
+	"37":   {"N..8", false},     // COUNT
+	"390n": {"N..15", false},    // AMOUNT
+	"391n": {"N3+N..15", false}, // AMOUNT with ISO currency code
+	"392n": {"N..15", false},    // PRICE
+	"393n": {"N3+N..15", false}, // PRICE with ISO currency code
+	"394n": {"N4", false},       // PRCNT OFF
+	"400":  {"X..30", false},    // ORDER NUMBER
+	"401":  {"X..30", false},    // GINC
+	"402":  {"N17", true},       // GSIN
+	"403":  {"X..30", false},    // ROUTE
+	"410":  {"N13", true},       // SHIP TO LOC
+	"411":  {"N13", true},       // BILL TO
+	"412":  {"N13", true},       // PURCHASE FROM
+	"413":  {"N13", true},       // SHIP FOR LOC
+	"414":  {"N13", true},       // LOC No.
+	"415":  {"N13", true},       // PAY TO
+	"416":  {"N13", true},       // PROD/SERV LOC
+	"417":  {"N13", true},       // PARTY
+	"420":  {"X..20", false},    // SHIP TO POST
+	"421":  {"N3+X..9", false},  // SHIP TO POST with ISO country code
+	"422":  {"N3", false},       // ORIGIN
+	"423":  {"N3+N..12", false}, // COUNTRY - INITIAL PROCESS.
+	"424":  {"N3", false},       // COUNTRY - PROCESS.
+	"425":  {"N3+N..12", false}, // COUNTRY - DISASSEMBLY
+	"426":  {"N3", false},       // COUNTRY - FULL PROCESS
+	"427":  {"X..3", false},     // ORIGIN SUBDIVISION
+	"7001": {"N13", false},      // NSN
+	"7002": {"X..30", false},    // MEAT CUT
+	"7003": {"N10", false},      // EXPIRY TIME
+	"7004": {"N..4", false},     // ACTIVE POTENCY
+	"7005": {"X..12", false},    // CATCH AREA
+	"7006": {"N6", false},       // FIRST FREEZE DATE
+	"7007": {"N6+N..6", false},  // HARVEST DATE
+	"7008": {"X..3", false},     // AQUATIC SPECIES
+	"7009": {"X..10", false},    // FISHING GEAR TYPE
+	"7010": {"X..2", false},     // PROD METHOD
+	"7020": {"X..20", false},    // REFURB LOT
+	"7021": {"X..20", false},    // FUNC STAT
+	"7022": {"X..20", false},    // REV STAT
+	"7023": {"X..30", false},    // GIAI - ASSEMBLY
+	"8001": {"N14", false},      // DIMENSIONS
+	"8002": {"X..20", false},    // CMT No.
+	"8003": {"N14+X..16", true}, // GRAI
+	"8004": {"X..30", false},    // GIAI
+	"8005": {"N6", false},       // PRICE PER UNIT
+	"8006": {"N14+N4", true},    // ITIP
+	"8007": {"X..34", false},    // IBAN
+	"8008": {"N8+N..4", false},  // PROD TIME
+	"8011": {"N..12", false},    // CPID SERIAL
+	"8012": {"X..20", false},    // VERSION
+	"8013": {"X..25", false},    // GMN
+	"8017": {"N18", true},       // GSRN - PROVIDER
+	"8018": {"N18", true},       // GSRN - RECIPIENT
+	"8019": {"N..10", false},    // SRIN
+	"8020": {"X..25", false},    // REF No.
+	"8026": {"N14+N4", true},    // ITIP CONTENT
+	"8110": {"X..70", false},    // Coupon code
+	"8111": {"N4", false},       // POINTS
+	"8112": {"X..70", false},    // Paperless coupon code
+	"8200": {"X..70", false},    // PRODUCT URL
+	"90":   {"X..30", false},    // INTERNAL
+	"91":   {"X..90", false},    // INTERNAL
+	"92":   {"X..90", false},    // INTERNAL
+	"93":   {"X..90", false},    // INTERNAL
+	"94":   {"X..90", false},    // INTERNAL
+	"95":   {"X..90", false},    // INTERNAL
+	"96":   {"X..90", false},    // INTERNAL
+	"97":   {"X..90", false},    // INTERNAL
+	"98":   {"X..90", false},    // INTERNAL
+	"99":   {"X..90", false},    // INTERNAL
+}
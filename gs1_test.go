@@ -0,0 +1,75 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGS1Data(t *testing.T) {
+	testCases := []struct {
+		elems []GS1Element
+		want  string
+	}{
+		{
+			[]GS1Element{{"01", "09506000134352"}},
+			"0109506000134352",
+		},
+		{
+			[]GS1Element{{"01", "09506000134352"}, {"10", "ABC123"}, {"17", "201231"}},
+			"0109506000134352" + "10ABC123\x1d" + "17201231",
+		},
+		{
+			[]GS1Element{{"00", "106141411234567897"}, {"3103", "000123"}, {"21", "12%34"}},
+			"00106141411234567897" + "3103000123" + "2112%34",
+		},
+		{
+			[]GS1Element{{"253", "9506000134352"}, {"8003", "09506000134352A1"}},
+			"2539506000134352\x1d" + "800309506000134352A1",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := gs1Data(tc.elems)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("have %q want %q", got, tc.want)
+		}
+	}
+}
+
+func TestGS1DataInvalid(t *testing.T) {
+	testCases := []struct {
+		elem GS1Element
+		want string
+	}{
+		{GS1Element{"01", "09506000134353"}, "invalid check digit"},
+		{GS1Element{"00", "10614141123456789"}, "too short"},
+		{GS1Element{"01", "095060001343521"}, "too long"},
+		{GS1Element{"11", "2012AB"}, "must be numeric"},
+		{GS1Element{"10", strings.Repeat("A", 21)}, "too long"},
+		{GS1Element{"10", ""}, "too short"},
+		{GS1Element{"21", "A\x1dB"}, "invalid character"},
+		{GS1Element{"5", "123"}, "unknown application identifier"},
+		{GS1Element{"3170", "123456"}, "unknown application identifier"},
+	}
+
+	for _, tc := range testCases {
+		_, err := gs1Data([]GS1Element{tc.elem})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: have %v want %s", tc.elem, err, tc.want)
+		}
+	}
+}
+
+func TestEncodeGS1(t *testing.T) {
+	elems := []GS1Element{{"01", "09506000134352"}, {"10", "ABC123"}, {"17", "201231"}}
+	c, err := EncodeGS1(elems, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 {
+		t.Errorf("have version %d want 2", c.Version)
+	}
+}
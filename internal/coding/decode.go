@@ -0,0 +1,650 @@
+package coding
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/cristalhq/qrcode/internal/gf256"
+)
+
+// Decoded is the content of a decoded code.
+type Decoded struct {
+	Version Version // version of the code
+	Level   Level   // error correction level
+	Mask    Mask    // mask applied to the data pixels
+	Data    []byte  // data bytes, after error correction
+	Text    string  // text stored in the data bytes
+	Errors  int     // number of bytes corrected by error correction
+	Blocks  []Block // error correction blocks, in order
+
+	Segments []DecodedSegment // segments of the data, in order
+	Unmasked *Code            // pixels as read, with the mask removed
+
+	// Append is the Structured Append header of the code,
+	// with a zero Total when it has none. DataParity is the parity
+	// of the data of this code alone: the parities of the codes
+	// of a sequence XOR to the parity in their headers.
+	Append     StructuredAppend
+	DataParity byte
+}
+
+// A Block is an error correction block of a decoded code.
+type Block struct {
+	Raw    []byte // data and check bytes as read, before correction
+	Data   []byte // corrected data bytes
+	Check  []byte // corrected check bytes
+	Errors int    // number of bytes corrected
+}
+
+// A DecodedSegment describes a segment of the data of a decoded code.
+type DecodedSegment struct {
+	Indicator uint // QR mode indicator: 1 numeric, 2 alphanumeric, 4 byte, 8 kanji, 7 ECI
+	ECI       int  // assignment number of an ECI segment
+	Start     int  // offset of the text of the segment in Decoded.Text
+	End       int  // offset of the end of the text of the segment
+	Offset    int  // offset of the segment in the data bits, header included
+	Bits      int  // number of bits of the segment
+}
+
+var (
+	errSize          = errors.New("bitmap is not the size of a QR code")
+	errFormat        = errors.New("cannot read format information")
+	errVersion       = errors.New("cannot read version information")
+	errTooManyErrors = errors.New("too many errors to correct")
+	errMalformed     = errors.New("malformed data segment")
+)
+
+// MaxDistance is the largest number of wrong bits
+// of format and version information that can be corrected.
+const MaxDistance = 3
+
+// Decode reads the code c, a QR, Micro QR or rMQR code:
+// its format and version information,
+// then its data and check bytes, which it corrects before
+// parsing the data segments.
+func Decode(c *Code) (*Decoded, error) {
+	v, la, ma, err := c.readFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewPlan(v, la, ma)
+	u := c.unmask(p)
+	blocks, err := correct(u.codewords(p), v, la)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decoded{
+		Version:  v,
+		Level:    la,
+		Mask:     ma,
+		Blocks:   blocks,
+		Unmasked: u,
+	}
+	for _, b := range blocks {
+		d.Data = append(d.Data, b.Data...)
+		d.Errors += b.Errors
+	}
+	if err := d.parse(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readFormat returns the version, the level and the mask of c,
+// from its size and its format and version information.
+func (c *Code) readFormat() (Version, Level, Mask, error) {
+	w, h := c.Width, c.Height
+	switch {
+	case w != h:
+		return c.readRectFormat()
+	case w < 21:
+		return c.readMicroFormat()
+	case w > 177 || (w-17)%4 != 0:
+		return 0, 0, 0, errSize
+	}
+	v := Version((w - 17) / 4)
+	if v >= 7 {
+		if cv, ok := c.ReadVersion(); !ok || cv != v {
+			return 0, 0, 0, errVersion
+		}
+	}
+
+	a, b := c.formatBits()
+	la, ma, da := DecodeFormat(a)
+	lb, mb, db := DecodeFormat(b)
+	if db < da {
+		la, ma, da = lb, mb, db
+	}
+	if da > MaxDistance {
+		return 0, 0, 0, errFormat
+	}
+	return v, la, ma, nil
+}
+
+// readMicroFormat returns the version, the level and the mask
+// of c, a Micro QR code, from its size and its format information.
+func (c *Code) readMicroFormat() (Version, Level, Mask, error) {
+	w := c.Width
+	if w < 11 || w > 17 || w%2 == 0 {
+		return 0, 0, 0, errSize
+	}
+	v := M1 + Version(w-11)/2
+
+	// The single copy of the format information, as microPlan places it.
+	var fb uint32
+	for i := 0; i < 15; i++ {
+		x, y := 8, i+1
+		if i >= 8 {
+			x, y = 15-i, 8
+		}
+		if c.Black(x, y) {
+			fb |= 1 << uint(i)
+		}
+	}
+
+	mt := &mtab[v-M1]
+	var level Level
+	var mask Mask
+	best := 16
+	for l := L; l <= H; l++ {
+		if mt.check[l] == 0 {
+			continue
+		}
+		for m := Mask(0); m < 4; m++ {
+			if d := bits.OnesCount32(microFormatWord(mt.symbol[l], m) ^ fb); d < best {
+				level, mask, best = l, m, d
+			}
+		}
+	}
+	if best > MaxDistance {
+		return 0, 0, 0, errFormat
+	}
+	return v, level, mask, nil
+}
+
+// microFormatWord returns the 15-bit format information of a Micro QR code
+// with symbol number symbol and mask m, as microPlan places it.
+func microFormatWord(symbol int, m Mask) uint32 {
+	fb := uint32(symbol)<<12 | uint32(m)<<10
+	return (fb | bchRemainder(fb)) ^ 0x4445
+}
+
+// readRectFormat returns the version, the level and the mask
+// of c, an rMQR code, from its format information, which must
+// match its size.
+func (c *Code) readRectFormat() (Version, Level, Mask, error) {
+	w, h := c.Width, c.Height
+	var a, b uint32
+	for i := 0; i < 18; i++ {
+		// The two copies, as rectPlan places them.
+		ax, ay, bx, by := 8+i/5, 1+i%5, w-8+i/5, h-6+i%5
+		if i >= 15 {
+			ax, ay, bx, by = 11, 1+i-15, w-5+i-15, h-6
+		}
+		if c.Black(ax, ay) {
+			a |= 1 << uint(i)
+		}
+		if c.Black(bx, by) {
+			b |= 1 << uint(i)
+		}
+	}
+
+	var version Version
+	var level Level
+	best := 19
+	for v := R7x43; v <= R17x139; v++ {
+		for _, l := range []Level{M, H} {
+			fb := rectFormatWord(v, l)
+			da := bits.OnesCount32(fb ^ 0x1fab2 ^ a)
+			db := bits.OnesCount32(fb ^ 0x20a7b ^ b)
+			if da > db {
+				da = db
+			}
+			if da < best {
+				version, level, best = v, l, da
+			}
+		}
+	}
+	if vw, vh := version.Dims(); best > MaxDistance || vw != w || vh != h {
+		return 0, 0, 0, errFormat
+	}
+	return version, level, rectMask, nil
+}
+
+// rectFormatWord returns the 18-bit format information of an rMQR code
+// with version v and level l, before the mask of either copy.
+func rectFormatWord(v Version, l Level) uint32 {
+	fb := uint32(v-R7x43) | uint32(rectLevel(l))<<5
+	return fb<<12 | rectBCHRemainder(fb<<12)
+}
+
+// ReadVersion returns the version stored in the version information
+// of c, a QR code of version 7 or more, and reports whether either
+// copy of it could be read. The version may not match the size of c
+// when c was sampled from an image with a wrong estimate of its size.
+func (c *Code) ReadVersion() (Version, bool) {
+	a, b := c.versionBits()
+	va, da := DecodeVersion(a)
+	vb, db := DecodeVersion(b)
+	if db < da {
+		va, da = vb, db
+	}
+	return va, da <= MaxDistance
+}
+
+// formatBits returns the two copies of the format information of c,
+// read from the pixels fplan places them in.
+func (c *Code) formatBits() (a, b uint32) {
+	siz := c.Size
+	for i := 0; i < 15; i++ {
+		var x, y int
+		switch {
+		case i < 6:
+			x, y = 8, i
+		case i < 8:
+			x, y = 8, i+1
+		case i < 9:
+			x, y = 7, 8
+		default:
+			x, y = 14-i, 8
+		}
+		if c.Black(x, y) {
+			a |= 1 << uint(i)
+		}
+		if i < 8 {
+			x, y = siz-1-i, 8
+		} else {
+			x, y = 8, siz-1-(14-i)
+		}
+		if c.Black(x, y) {
+			b |= 1 << uint(i)
+		}
+	}
+	return a, b
+}
+
+// versionBits returns the two copies of the version information of c,
+// read from the pixels vplan places them in.
+func (c *Code) versionBits() (a, b uint32) {
+	siz := c.Size
+	for x := 0; x < 6; x++ {
+		for y := 0; y < 3; y++ {
+			i := uint(x*3 + y)
+			if c.Black(x, siz-11+y) {
+				a |= 1 << i
+			}
+			if c.Black(siz-11+y, x) {
+				b |= 1 << i
+			}
+		}
+	}
+	return a, b
+}
+
+// formatWord returns the 15-bit format information of a QR code
+// with level l and mask m, as fplan places it.
+func formatWord(l Level, m Mask) uint32 {
+	fb := uint32(l^1)<<13 | uint32(m)<<10
+	return (fb | bchRemainder(fb)) ^ 0x5412
+}
+
+// DecodeFormat returns the level and the mask of the QR format information
+// closest to fb, a 15-bit word as read from a code, and the number of bits
+// they differ in. The format words are at least 7 bits apart,
+// so up to 3 wrong bits are corrected.
+func DecodeFormat(fb uint32) (Level, Mask, int) {
+	var level Level
+	var mask Mask
+	best := 16
+	for l := L; l <= H; l++ {
+		for m := Mask(0); m < 8; m++ {
+			if d := bits.OnesCount32(formatWord(l, m) ^ fb); d < best {
+				level, mask, best = l, m, d
+			}
+		}
+	}
+	return level, mask, best
+}
+
+// DecodeVersion returns the version of the version information
+// closest to vb, an 18-bit word as read from a code of version 7 or more,
+// and the number of bits they differ in. The version words are
+// at least 8 bits apart, so up to 3 wrong bits are corrected.
+func DecodeVersion(vb uint32) (Version, int) {
+	var version Version
+	best := 19
+	for v := Version(7); v <= MaxVersion; v++ {
+		if d := bits.OnesCount32(uint32(vtab[v].pattern) ^ vb); d < best {
+			version, best = v, d
+		}
+	}
+	return version, best
+}
+
+// unmask returns a copy of c with the mask of p
+// removed from its data, check and remainder pixels.
+func (c *Code) unmask(p *Plan) *Code {
+	u := *c
+	u.Bitmap = append([]byte(nil), c.Bitmap...)
+	u.Version, u.Level, u.Mask = p.Version, p.Level, p.Mask
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case Data, Check, Extra:
+				if pix&Invert != 0 {
+					u.Bitmap[y*u.Stride+x/8] ^= 1 << uint(7-x&7)
+				}
+			}
+		}
+	}
+	return &u
+}
+
+// codewords returns the data and check bytes of c, laid out by p.
+// The mask must have been removed from c, as unmask does.
+func (c *Code) codewords(p *Plan) []byte {
+	raw := make([]byte, p.DataBytes+p.CheckBytes)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case Data, Check:
+				if c.Black(x, y) {
+					o := pix.Offset()
+					raw[o/8] |= 1 << (7 - o&7)
+				}
+			}
+		}
+	}
+	return raw
+}
+
+// correct splits raw, the data bytes followed by the check bytes
+// of a code with version v and level l, into blocks and corrects them.
+func correct(raw []byte, v Version, l Level) ([]Block, error) {
+	_, nblock, check := v.blocks(l)
+	nd := v.DataBytes(l)
+	db := nd / nblock
+	extra := nd % nblock
+	dat, chk := raw[:nd], raw[nd:]
+
+	blocks := make([]Block, nblock)
+	rs := gf256.NewRSDecoder(qrField, check)
+	for i := range blocks {
+		n := db
+		if i >= nblock-extra {
+			n++
+		}
+		b := &blocks[i]
+		b.Raw = make([]byte, 0, n+check)
+		b.Raw = append(append(b.Raw, dat[:n]...), chk[:check]...)
+		dat, chk = dat[n:], chk[check:]
+		fixed := append([]byte(nil), b.Raw...)
+		k, err := rs.Decode(fixed, nil)
+		if err != nil {
+			return nil, errTooManyErrors
+		}
+		b.Data, b.Check, b.Errors = fixed[:n], fixed[n:], k
+	}
+	return blocks, nil
+}
+
+// Codewords returns the corrected data bytes of d followed by
+// its corrected check bytes, as a Plan lays them out.
+func (d *Decoded) Codewords() []byte {
+	var data, check []byte
+	for _, b := range d.Blocks {
+		data = append(data, b.Data...)
+		check = append(check, b.Check...)
+	}
+	return append(data, check...)
+}
+
+// BlockOf returns the index in d.Blocks of the block
+// of the codeword at index k in d.Codewords.
+func (d *Decoded) BlockOf(k int) int {
+	for i, b := range d.Blocks {
+		if k < len(b.Data) {
+			return i
+		}
+		k -= len(b.Data)
+	}
+	return k / len(d.Blocks[0].Check)
+}
+
+// A bitReader reads bits from a byte slice, most significant first.
+type bitReader struct {
+	b    []byte
+	off  int
+	size int // number of bits to read
+}
+
+// left returns the number of bits left to read.
+func (r *bitReader) left() int {
+	return r.size - r.off
+}
+
+// read returns the next n bits, 0 for the bits past the end.
+func (r *bitReader) read(n int) uint {
+	var v uint
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if o := r.off; o < r.size && r.b[o/8]&(1<<uint(7-o&7)) != 0 {
+			v |= 1
+		}
+		r.off++
+	}
+	return v
+}
+
+// parse sets the text, the Structured Append header
+// and the data parity of d from its data bytes.
+func (d *Decoded) parse() error {
+	v := d.Version
+	r := &bitReader{b: d.Data, size: v.DataBits(d.Level)}
+	var text []byte
+	gs1 := false
+	for {
+		start, at := r.off, len(text)
+		ind, ok := readIndicator(r, v)
+		if !ok { // terminator
+			d.Text = string(text)
+			return nil
+		}
+		var m mode
+		switch ind {
+		case indicator[modeNum]:
+			m = modeNum
+		case indicator[modeAlpha]:
+			m = modeAlpha
+		case indicator[modeString]:
+			m = modeString
+		case indicator[modeKanji]:
+			m = modeKanji
+		case 7: // ECI, the text is kept in its own character set
+			eci, ok := readECI(r)
+			if !ok {
+				return errMalformed
+			}
+			d.Segments = append(d.Segments, DecodedSegment{
+				Indicator: ind, ECI: eci, Start: at, End: at, Offset: start, Bits: r.off - start,
+			})
+			continue
+		case 5: // FNC1 in the first position
+			gs1 = true
+			continue
+		case 9: // FNC1 in the second position, with an application indicator
+			r.read(8)
+			gs1 = true
+			continue
+		case 3: // Structured Append
+			d.Append.Index = int(r.read(4))
+			d.Append.Total = int(r.read(4)) + 1
+			d.Append.Parity = byte(r.read(8))
+			continue
+		default:
+			return errMalformed
+		}
+
+		count := int(r.read(v.countBits(m)))
+		text, ok = parseSegment(r, text, m, count, gs1)
+		if !ok {
+			return errMalformed
+		}
+		if m == modeKanji {
+			d.DataParity ^= Parity(Kanji(text[at:]))
+		} else {
+			d.DataParity ^= Parity(String(text[at:]))
+		}
+		d.Segments = append(d.Segments, DecodedSegment{
+			Indicator: indicator[m], Start: at, End: len(text), Offset: start, Bits: r.off - start,
+		})
+	}
+}
+
+// readIndicator reads the mode indicator of the next segment of a code
+// with version v and returns it as the QR indicator of the same mode.
+// It reports false at the terminator or at the end of the data.
+func readIndicator(r *bitReader, v Version) (uint, bool) {
+	switch {
+	case v.Micro():
+		// The terminator is a run of zeros as long as the shortest
+		// header, that of a numeric segment with no digits.
+		n := int(v - M1)
+		term := n + microCountLen[modeNum][n]
+		if r.left() < term {
+			return 0, false
+		}
+		if save := *r; r.read(term) == 0 {
+			return 0, false
+		} else {
+			*r = save
+		}
+		ind := r.read(n)
+		for m, mi := range microIndicator {
+			if mi == ind && microCountLen[m][n] != 0 {
+				return indicator[m], true
+			}
+		}
+		return 0, true // no such mode
+	case v.Rect():
+		if r.left() < 3 {
+			return 0, false
+		}
+		switch ind := r.read(3); ind {
+		case 0:
+			return 0, false
+		case 5, 7: // FNC1 in the first position, ECI
+			return ind, true
+		case 6: // FNC1 in the second position
+			return 9, true
+		default:
+			for m, ri := range rectIndicator {
+				if ri == ind {
+					return indicator[m], true
+				}
+			}
+			return 0, true
+		}
+	}
+	if r.left() < 4 {
+		return 0, false
+	}
+	ind := r.read(4)
+	return ind, ind != 0
+}
+
+// readECI reads an ECI assignment number and reports whether it is valid.
+func readECI(r *bitReader) (int, bool) {
+	b := r.read(8)
+	switch {
+	case b&0x80 == 0:
+	case b&0xc0 == 0x80:
+		b = b&0x3f<<8 | r.read(8)
+	case b&0xe0 == 0xc0:
+		b = b&0x1f<<16 | r.read(16)
+	default:
+		return 0, false
+	}
+	return int(b), r.left() >= 0
+}
+
+// parseSegment appends to text the count characters
+// of a segment in mode m read from r.
+// It reports whether r holds all of them.
+func parseSegment(r *bitReader, text []byte, m mode, count int, gs1 bool) ([]byte, bool) {
+	switch m {
+	case modeNum:
+		for ; count >= 3; count -= 3 {
+			w := r.read(10)
+			if w >= 1000 {
+				return nil, false
+			}
+			text = append(text, byte('0'+w/100), byte('0'+w/10%10), byte('0'+w%10))
+		}
+		switch count {
+		case 1:
+			w := r.read(4)
+			if w >= 10 {
+				return nil, false
+			}
+			text = append(text, byte('0'+w))
+		case 2:
+			w := r.read(7)
+			if w >= 100 {
+				return nil, false
+			}
+			text = append(text, byte('0'+w/10), byte('0'+w%10))
+		}
+	case modeAlpha:
+		start := len(text)
+		for ; count >= 2; count -= 2 {
+			w := r.read(11)
+			if w >= 45*45 {
+				return nil, false
+			}
+			text = append(text, alphabet[w/45], alphabet[w%45])
+		}
+		if count == 1 {
+			w := r.read(6)
+			if w >= 45 {
+				return nil, false
+			}
+			text = append(text, alphabet[w])
+		}
+		if gs1 {
+			text = append(text[:start], unescapeGS1(text[start:])...)
+		}
+	case modeString:
+		for ; count > 0; count-- {
+			text = append(text, byte(r.read(8)))
+		}
+	case modeKanji:
+		for ; count > 0; count-- {
+			c := kanjiTab[r.read(13)]
+			if c == 0 {
+				return nil, false
+			}
+			text = append(text, string(rune(c))...)
+		}
+	}
+	return text, r.left() >= 0
+}
+
+// unescapeGS1 reverses the escaping of newSegment: in alphanumeric
+// segments of GS1 data, %% stands for % and % for the GS separator.
+func unescapeGS1(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] != '%':
+			out = append(out, s[i])
+		case i+1 < len(s) && s[i+1] == '%':
+			out = append(out, '%')
+			i++
+		default:
+			out = append(out, GS)
+		}
+	}
+	return out
+}
@@ -0,0 +1,335 @@
+package coding
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		text  string
+		level Level
+	}{
+		{"HELLO WORLD", M},
+		{"https://github.com/cristalhq/qrcode", L},
+		{"0123456789" + strings.Repeat("ABC", 100), Q},
+		{"コードの テスト", H},
+		{strings.Repeat("hello, world ", 200), L},
+		{"unicode: ∀x∈ℝ", M},
+	}
+
+	for _, tc := range testCases {
+		c, err := Encode(nil, tc.text, tc.level, nil)
+		if err != nil {
+			t.Fatalf("%.20q: %v", tc.text, err)
+		}
+		d, err := Decode(c)
+		if err != nil {
+			t.Fatalf("%.20q: %v", tc.text, err)
+		}
+		if d.Text != tc.text || d.Version != c.Version || d.Level != tc.level || d.Mask != c.Mask || d.Errors != 0 {
+			t.Errorf("%.20q: have %.20q version %v level %v mask %d errors %d, want version %v mask %d",
+				tc.text, d.Text, d.Version, d.Level, d.Mask, d.Errors, c.Version, c.Mask)
+		}
+	}
+}
+
+func TestDecodeVersions(t *testing.T) {
+	for v := MinVersion; v <= MaxVersion; v++ {
+		for l := L; l <= H; l++ {
+			opts := Options{MinVersion: v, MaxVersion: v, Mask: Mask(int(v) % 8)}
+			c, err := Encode(nil, "VERSION "+v.String(), l, &opts)
+			if err != nil {
+				t.Fatalf("%v-%v: %v", v, l, err)
+			}
+			d, err := Decode(c)
+			if err != nil {
+				t.Fatalf("%v-%v: %v", v, l, err)
+			}
+			if d.Text != "VERSION "+v.String() || d.Version != v || d.Level != l {
+				t.Errorf("%v-%v: have %q version %v level %v", v, l, d.Text, d.Version, d.Level)
+			}
+		}
+	}
+}
+
+func TestDecodeMicro(t *testing.T) {
+	testCases := []struct {
+		text    string
+		version Version
+	}{
+		{"12345", M1},
+		{"ABC", M2},
+		{"hello", M3},
+		{"Micro QR 42", M4},
+		{"コード", M4},
+	}
+
+	for _, tc := range testCases {
+		for l := L; l <= H; l++ {
+			for m := Mask(0); m < 4; m++ {
+				opts := Options{MinVersion: tc.version, MaxVersion: tc.version, Mask: m}
+				c, err := Encode(nil, tc.text, l, &opts)
+				if err != nil {
+					continue // no such level, or the text does not fit
+				}
+				d, err := Decode(c)
+				if err != nil {
+					t.Fatalf("%q %v-%v mask %d: %v", tc.text, tc.version, l, m, err)
+				}
+				if d.Text != tc.text || d.Version != tc.version || d.Level != l || d.Mask != m {
+					t.Errorf("%q %v-%v mask %d: have %q version %v level %v mask %d",
+						tc.text, tc.version, l, m, d.Text, d.Version, d.Level, d.Mask)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRect(t *testing.T) {
+	for v := R7x43; v <= R17x139; v++ {
+		for _, l := range []Level{M, H} {
+			opts := Options{MinVersion: v, MaxVersion: v}
+			text := strconv.Itoa(int(v))
+			c, err := Encode(nil, text, l, &opts)
+			if err != nil {
+				t.Fatalf("%v-%v: %v", v, l, err)
+			}
+			d, err := Decode(c)
+			if err != nil {
+				t.Fatalf("%v-%v: %v", v, l, err)
+			}
+			if d.Text != text || d.Version != v || d.Level != l || d.Mask != rectMask {
+				t.Errorf("%v-%v: have %q version %v level %v", v, l, d.Text, d.Version, d.Level)
+			}
+		}
+	}
+}
+
+func TestDecodeDamaged(t *testing.T) {
+	opts := Options{MinVersion: 7, MaxVersion: 7, Mask: 3}
+	c, err := Encode(nil, "damaged but readable", H, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(x, y int) {
+		c.Bitmap[y*c.Stride+x/8] ^= 1 << uint(7-x&7)
+	}
+	// Two bits of each copy of the format and version information.
+	flip(8, 0)
+	flip(8, 1)
+	flip(c.Size-1, 8)
+	flip(8, c.Size-1)
+	flip(0, c.Size-11)
+	flip(c.Size-11, 1)
+	// A square of data pixels.
+	for y := 12; y < 16; y++ {
+		for x := 28; x < 32; x++ {
+			flip(x, y)
+		}
+	}
+
+	d, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Text != "damaged but readable" || d.Errors == 0 {
+		t.Errorf("have %q with %d errors", d.Text, d.Errors)
+	}
+
+	// Wipe out the lower half.
+	for y := c.Size / 2; y < c.Size; y++ {
+		for x := 9; x < c.Size; x++ {
+			flip(x, y)
+		}
+	}
+	if _, err := Decode(c); err != errTooManyErrors {
+		t.Errorf("have %v want %v", err, errTooManyErrors)
+	}
+}
+
+func TestDecodeGS1(t *testing.T) {
+	data := "01095060001343521012%AB" + string(GS) + "21XYZ"
+	c, err := EncodeGS1(nil, data, M, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Text != data {
+		t.Errorf("have %q want %q", d.Text, data)
+	}
+}
+
+func TestDecodeStructuredAppend(t *testing.T) {
+	latin := strings.Repeat("Structured Append, ünïcode too. ", 6)
+	kanji := strings.Repeat("漢字点", 41)
+	for _, tc := range []struct {
+		text   string
+		parity byte // XOR of the UTF-8 bytes, or of the Shift JIS ones
+	}{
+		{latin, Parity(String(latin))},
+		{kanji, Parity(Kanji(kanji))},
+	} {
+		text := tc.text
+		codes, err := EncodeSplit(text, M, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var joined string
+		var parity byte
+		for _, c := range codes {
+			d, err := Decode(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			joined += d.Text
+			parity ^= d.DataParity
+		}
+		if joined != text || parity != tc.parity {
+			t.Errorf("have %q parity %#02x want %q parity %#02x", joined, parity, text, tc.parity)
+		}
+		for i, c := range codes {
+			d, _ := Decode(c)
+			want := StructuredAppend{Index: i, Total: len(codes), Parity: tc.parity}
+			if d.Append != want {
+				t.Errorf("part %d: have %v want %v", i, d.Append, want)
+			}
+		}
+	}
+}
+
+func TestDecodeFormat(t *testing.T) {
+	for l := L; l <= H; l++ {
+		for m := Mask(0); m < 8; m++ {
+			fb := formatWord(l, m) ^ 0x4010
+			if dl, dm, d := DecodeFormat(fb); dl != l || dm != m || d != 2 {
+				t.Errorf("%v/%d: have %v/%d at distance %d", l, m, dl, dm, d)
+			}
+		}
+	}
+}
+
+func TestDecodeVersion(t *testing.T) {
+	for v := Version(7); v <= MaxVersion; v++ {
+		vb := uint32(vtab[v].pattern) ^ 0x20201
+		if dv, d := DecodeVersion(vb); dv != v || d != 3 {
+			t.Errorf("%v: have %v at distance %d", v, dv, d)
+		}
+	}
+}
+
+func TestDecodeBlocks(t *testing.T) {
+	c, err := Encode(nil, strings.Repeat("blocks ", 20), Q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, nblock, check := c.Version.blocks(Q)
+	if len(d.Blocks) != nblock || d.BlockOf(len(d.Codewords())-1) != nblock-1 {
+		t.Fatalf("have %d blocks want %d", len(d.Blocks), nblock)
+	}
+	for _, b := range d.Blocks {
+		if len(b.Check) != check || len(b.Raw) != len(b.Data)+check {
+			t.Errorf("have %d+%d bytes in a block", len(b.Data), len(b.Check))
+		}
+	}
+
+	// Laying out the codewords again gives back the code.
+	p := NewPlan(d.Version, d.Level, d.Mask)
+	for k := range d.Codewords() {
+		if p.BlockOf(k) != d.BlockOf(k) {
+			t.Fatalf("codeword %d: have block %d want %d", k, p.BlockOf(k), d.BlockOf(k))
+		}
+	}
+	if l := p.LayoutInto(nil, d.Codewords()); !bytes.Equal(l.Bitmap, c.Bitmap) {
+		t.Error("have a different layout")
+	}
+}
+
+func TestDecodeSegments(t *testing.T) {
+	enc := Segments{Num("0123"), ECI(26), String("héllo"), ECI(20000), Alpha("AB")}
+	c, err := EncodeData(nil, enc, M, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DecodedSegment{
+		{Indicator: 1, Start: 0, End: 4},
+		{Indicator: 7, ECI: 26, Start: 4, End: 4},
+		{Indicator: 4, Start: 4, End: 10},
+		{Indicator: 7, ECI: 20000, Start: 10, End: 10},
+		{Indicator: 2, Start: 10, End: 12},
+	}
+	if len(d.Segments) != len(want) {
+		t.Fatalf("have %d segments want %d", len(d.Segments), len(want))
+	}
+	off := 0
+	for i, s := range d.Segments {
+		if s.Offset != off || s.Bits != enc[i].Bits(c.Version) {
+			t.Errorf("segment %d: have bits %d+%d want %d+%d", i, s.Offset, s.Bits, off, enc[i].Bits(c.Version))
+		}
+		off += s.Bits
+		s.Offset, s.Bits = 0, 0
+		if s != want[i] {
+			t.Errorf("segment %d: have %+v want %+v", i, s, want[i])
+		}
+	}
+}
+
+func TestDecodeUnmasked(t *testing.T) {
+	var unmasked []*Code
+	for _, m := range []Mask{0, 5} {
+		opts := Options{MinVersion: 3, MaxVersion: 3, Mask: m}
+		c, err := Encode(nil, "unmasked", L, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := Decode(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmasked = append(unmasked, d.Unmasked)
+	}
+
+	// Only the format information differs without the masks.
+	p := NewPlan(3, L, 0)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if pix.Role() != Format && unmasked[0].Black(x, y) != unmasked[1].Black(x, y) {
+				t.Fatalf("have %v pixel %d,%d differing", pix.Role(), x, y)
+			}
+		}
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	c, err := Encode(nil, "1", M, &Options{MinVersion: R7x43, MaxVersion: R7x43})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := c.Transpose()
+	if tc.Width != c.Height || tc.Height != c.Width {
+		t.Fatalf("have size %dx%d", tc.Width, tc.Height)
+	}
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			if tc.Black(y, x) != c.Black(x, y) {
+				t.Fatalf("have pixel %d,%d differing", x, y)
+			}
+		}
+	}
+	if back := tc.Transpose(); !bytes.Equal(back.Bitmap, c.Bitmap) || back.Stride != c.Stride {
+		t.Error("have a different code transposed twice")
+	}
+}
@@ -3,8 +3,91 @@ package coding
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
+// noFit is the number of bits of a segment a version has no mode for.
+// It is larger than the capacity of any version.
+const noFit = 1 << 24
+
+// Mode indicators of QR, Micro QR and rMQR codes and the number of bits
+// of the character count, by version size class for QR and by
+// version for Micro QR. A zero count size means no such mode.
+// The rMQR character count sizes are in rtab.
+var (
+	indicator      = [numModes]uint{modeString: 4, modeAlpha: 2, modeNum: 1, modeKanji: 8}
+	microIndicator = [numModes]uint{modeString: 2, modeAlpha: 1, modeNum: 0, modeKanji: 3}
+	rectIndicator  = [numModes]uint{modeString: 3, modeAlpha: 2, modeNum: 1, modeKanji: 4}
+
+	countLen = [numModes][3]int{
+		modeString: {8, 16, 16},
+		modeAlpha:  {9, 11, 13},
+		modeNum:    {10, 12, 14},
+		modeKanji:  {8, 10, 12},
+	}
+	microCountLen = [numModes][4]int{
+		modeString: {0, 0, 4, 5},
+		modeAlpha:  {0, 3, 4, 5},
+		modeNum:    {3, 4, 5, 6},
+		modeKanji:  {0, 0, 3, 4},
+	}
+)
+
+// headerBits returns the number of bits of the mode indicator
+// and the character count of a segment in mode m.
+func (v Version) headerBits(m mode) int {
+	if v.Micro() {
+		n := microCountLen[m][v-M1]
+		if n == 0 {
+			return noFit
+		}
+		return int(v-M1) + n
+	}
+	if v.Rect() {
+		return 3 + rtab[v-R7x43].count[m]
+	}
+	return 4 + countLen[m][v.sizeClass()]
+}
+
+// indicatorBits returns the number of bits of the mode indicators
+// of QR and rMQR codes.
+func (v Version) indicatorBits() int {
+	if v.Rect() {
+		return 3
+	}
+	return 4
+}
+
+// countBits returns the number of bits of the character count
+// of a segment in mode m, 0 if version v has no such mode.
+func (v Version) countBits(m mode) int {
+	if v.Micro() {
+		return microCountLen[m][v-M1]
+	}
+	if v.Rect() {
+		return rtab[v-R7x43].count[m]
+	}
+	return countLen[m][v.sizeClass()]
+}
+
+// writeHeader writes the mode indicator and the character count
+// of a segment in mode m with count characters.
+func (v Version) writeHeader(b *Bits, m mode, count int) {
+	if v.Micro() {
+		b.Write(microIndicator[m], int(v-M1))
+		b.Write(uint(count), microCountLen[m][v-M1])
+		return
+	}
+	if v.Rect() {
+		b.Write(rectIndicator[m], 3)
+		b.Write(uint(count), rtab[v-R7x43].count[m])
+		return
+	}
+	b.Write(indicator[m], 4)
+	b.Write(uint(count), countLen[m][v.sizeClass()])
+}
+
 // Num is the encoding for numeric data.
 // The only valid characters are the decimal digits 0 through 9.
 type Num string
@@ -22,15 +105,12 @@ func (s Num) Check() bool {
 	return true
 }
 
-var numLen = [3]int{10, 12, 14}
-
 func (s Num) Bits(v Version) int {
-	return 4 + numLen[v.sizeClass()] + (10*len(s)+2)/3
+	return v.headerBits(modeNum) + (10*len(s)+2)/3
 }
 
 func (s Num) Encode(b *Bits, v Version) {
-	b.Write(1, 4)
-	b.Write(uint(len(s)), numLen[v.sizeClass()])
+	v.writeHeader(b, modeNum, len(s))
 	var i int
 	for i = 0; i+3 <= len(s); i += 3 {
 		w := uint(s[i]-'0')*100 + uint(s[i+1]-'0')*10 + uint(s[i+2]-'0')
@@ -65,15 +145,12 @@ func (s Alpha) Check() bool {
 	return true
 }
 
-var alphaLen = [3]int{9, 11, 13}
-
 func (s Alpha) Bits(v Version) int {
-	return 4 + alphaLen[v.sizeClass()] + (11*len(s)+1)/2
+	return v.headerBits(modeAlpha) + (11*len(s)+1)/2
 }
 
 func (s Alpha) Encode(b *Bits, v Version) {
-	b.Write(2, 4)
-	b.Write(uint(len(s)), alphaLen[v.sizeClass()])
+	v.writeHeader(b, modeAlpha, len(s))
 	var i int
 	for i = 0; i+2 <= len(s); i += 2 {
 		w := uint(strings.IndexRune(alphabet, rune(s[i])))*45 +
@@ -96,16 +173,202 @@ func (s String) String() string {
 
 func (s String) Check() bool { return true }
 
-var stringLen = [3]int{8, 16, 16}
-
 func (s String) Bits(v Version) int {
-	return 4 + stringLen[v.sizeClass()] + 8*len(s)
+	return v.headerBits(modeString) + 8*len(s)
 }
 
 func (s String) Encode(b *Bits, v Version) {
-	b.Write(4, 4)
-	b.Write(uint(len(s)), stringLen[v.sizeClass()])
+	v.writeHeader(b, modeString, len(s))
 	for i := 0; i < len(s); i++ {
 		b.Write(uint(s[i]), 8)
 	}
 }
+
+// Kanji is the encoding for double-byte Shift JIS characters.
+// The text is UTF-8 and is converted to Shift JIS while encoding.
+// The valid characters are the ones of JIS X 0208.
+type Kanji string
+
+func (s Kanji) String() string {
+	return fmt.Sprintf("Kanji(%#q)", string(s))
+}
+
+func (s Kanji) Check() bool {
+	for _, c := range s {
+		if _, ok := kanjiValue(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Kanji) Bits(v Version) int {
+	return v.headerBits(modeKanji) + 13*utf8.RuneCountInString(string(s))
+}
+
+func (s Kanji) Encode(b *Bits, v Version) {
+	v.writeHeader(b, modeKanji, utf8.RuneCountInString(string(s)))
+	for _, c := range s {
+		w, _ := kanjiValue(c)
+		b.Write(uint(w), 13)
+	}
+}
+
+var (
+	kanjiOnce  sync.Once
+	kanjiRunes map[rune]uint16
+)
+
+// kanjiValue returns the 13-bit Kanji mode value of c.
+func kanjiValue(c rune) (uint16, bool) {
+	kanjiOnce.Do(func() {
+		kanjiRunes = make(map[rune]uint16, 7000)
+		for w, r := range kanjiTab {
+			if r != 0 {
+				kanjiRunes[rune(r)] = uint16(w)
+			}
+		}
+	})
+	w, ok := kanjiRunes[c]
+	return w, ok
+}
+
+// Segments is an encoding made of several segments,
+// each of them encoded in its own mode.
+type Segments []Encoding
+
+func (s Segments) String() string {
+	return fmt.Sprint([]Encoding(s))
+}
+
+func (s Segments) Check() bool {
+	for _, e := range s {
+		if !e.Check() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Segments) Bits(v Version) int {
+	n := 0
+	for _, e := range s {
+		n += e.Bits(v)
+	}
+	return n
+}
+
+func (s Segments) Encode(b *Bits, v Version) {
+	for _, e := range s {
+		e.Encode(b, v)
+	}
+}
+
+// ECI is an Extended Channel Interpretation segment.
+// It switches the character set of the following 8-bit data
+// to the one with the given assignment number.
+type ECI uint32
+
+// ECI assignment numbers of common character sets.
+const (
+	ISO8859_1   ECI = 3
+	ISO8859_2   ECI = 4
+	ISO8859_3   ECI = 5
+	ISO8859_4   ECI = 6
+	ISO8859_5   ECI = 7
+	ISO8859_6   ECI = 8
+	ISO8859_7   ECI = 9
+	ISO8859_8   ECI = 10
+	ISO8859_9   ECI = 11
+	ISO8859_10  ECI = 12
+	ISO8859_11  ECI = 13
+	ISO8859_13  ECI = 15
+	ISO8859_14  ECI = 16
+	ISO8859_15  ECI = 17
+	ISO8859_16  ECI = 18
+	ShiftJIS    ECI = 20
+	Windows1250 ECI = 21
+	Windows1251 ECI = 22
+	Windows1252 ECI = 23
+	Windows1256 ECI = 24
+	UTF16BE     ECI = 25
+	UTF8        ECI = 26
+	ASCII       ECI = 27
+	Big5        ECI = 28
+	GB18030     ECI = 29
+	EUCKR       ECI = 30
+)
+
+func (s ECI) String() string {
+	return fmt.Sprintf("ECI(%d)", uint32(s))
+}
+
+func (s ECI) Check() bool { return s <= 999999 }
+
+func (s ECI) Bits(v Version) int {
+	switch {
+	case v.Micro():
+		return noFit
+	case s < 1<<7:
+		return v.indicatorBits() + 8
+	case s < 1<<14:
+		return v.indicatorBits() + 16
+	default:
+		return v.indicatorBits() + 24
+	}
+}
+
+func (s ECI) Encode(b *Bits, v Version) {
+	b.Write(7, v.indicatorBits())
+	switch {
+	case s < 1<<7:
+		b.Write(uint(s), 8)
+	case s < 1<<14:
+		b.Write(2<<14|uint(s), 16)
+	default:
+		b.Write(6<<21|uint(s), 24)
+	}
+}
+
+// withUTF8 prefixes enc with the UTF-8 ECI
+// when it stores non-ASCII text as 8-bit data.
+func withUTF8(enc Encoding) Encoding {
+	segs, ok := enc.(Segments)
+	if !ok {
+		segs = Segments{enc}
+	}
+	for _, e := range segs {
+		if s, ok := e.(String); ok && !isASCII(string(s)) {
+			return append(Segments{UTF8}, segs...)
+		}
+	}
+	return enc
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// FNC1 marks the data that follows it as GS1 element strings,
+// when it is in the first position of a code.
+type FNC1 struct{}
+
+func (FNC1) String() string { return "FNC1" }
+
+func (FNC1) Check() bool { return true }
+
+func (FNC1) Bits(v Version) int {
+	if v.Micro() {
+		return noFit
+	}
+	return v.indicatorBits()
+}
+
+func (FNC1) Encode(b *Bits, v Version) {
+	b.Write(5, v.indicatorBits())
+}
@@ -0,0 +1,79 @@
+package coding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKanji(t *testing.T) {
+	// Example from ISO/IEC 18004, section 7.4.6.
+	s := Kanji("点茗")
+	if !s.Check() {
+		t.Fatalf("%v.Check() = false", s)
+	}
+
+	var b Bits
+	s.Encode(&b, 1)
+	b.Pad(-b.Bits() & 7)
+	want := []byte{0x80, 0x26, 0xcf, 0xea, 0xa8}
+	if got := b.Bytes(); fmt.Sprintf("%x", got) != fmt.Sprintf("%x", want) {
+		t.Errorf("have %x want %x", got, want)
+	}
+	if got := s.Bits(1); got != 4+8+2*13 {
+		t.Errorf("Bits(1) = %d, want %d", got, 4+8+2*13)
+	}
+	if Kanji("abc").Check() {
+		t.Error("Kanji(abc).Check() = true")
+	}
+}
+
+func TestECI(t *testing.T) {
+	testCases := []struct {
+		eci  ECI
+		want string
+	}{
+		{UTF8, "0111" + "00011010"},
+		{ECI(127), "0111" + "01111111"},
+		{ECI(128), "0111" + "10" + "00000010000000"},
+		{ECI(16383), "0111" + "10" + "11111111111111"},
+		{ECI(16384), "0111" + "110" + "000000100000000000000"},
+		{ECI(999999), "0111" + "110" + "011110100001000111111"},
+	}
+
+	for _, tc := range testCases {
+		var b Bits
+		tc.eci.Encode(&b, 1)
+		if b.Bits() != tc.eci.Bits(1) {
+			t.Errorf("%v: wrote %d bits, Bits() = %d", tc.eci, b.Bits(), tc.eci.Bits(1))
+		}
+		if got := bitString(&b); got != tc.want {
+			t.Errorf("%v: have %s want %s", tc.eci, got, tc.want)
+		}
+	}
+}
+
+func TestWithUTF8(t *testing.T) {
+	testCases := []struct {
+		enc  Encoding
+		want string
+	}{
+		{String("hello"), "String(`hello`)"},
+		{String("привет"), "[ECI(26) String(`привет`)]"},
+		{Kanji("コード"), "Kanji(`コード`)"},
+		{Segments{Kanji("コード"), String("ü")}, "[ECI(26) Kanji(`コード`) String(`ü`)]"},
+	}
+
+	for _, tc := range testCases {
+		if got := fmt.Sprint(withUTF8(tc.enc)); got != tc.want {
+			t.Errorf("have %s want %s", got, tc.want)
+		}
+	}
+}
+
+func bitString(b *Bits) string {
+	s := make([]byte, b.Bits())
+	for i := range s {
+		s[i] = '0' + b.b[i/8]>>(7-i&7)&1
+	}
+	return string(s)
+}
@@ -0,0 +1,79 @@
+//go:build ignore
+// +build ignore
+
+// kanjigen generates the Kanji mode table kanji_table.go
+// from a Shift JIS mapping file in the format of the Unicode
+// consortium SHIFTJIS.TXT: "0xSJIS<tab>0xUnicode<tab># comment".
+//
+//	go run kanjigen.go SHIFTJIS.TXT > kanji_table.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var tab [8192]uint16
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		sjis, err := strconv.ParseUint(fields[0], 0, 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := strconv.ParseUint(fields[1], 0, 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Kanji mode covers 0x8140-0x9FFC and 0xE040-0xEBBF.
+		var d uint64
+		switch {
+		case 0x8140 <= sjis && sjis <= 0x9ffc:
+			d = sjis - 0x8140
+		case 0xe040 <= sjis && sjis <= 0xebbf:
+			d = sjis - 0xc140
+		default:
+			continue
+		}
+		tab[(d>>8)*0xc0+d&0xff] = uint16(r)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("// Code generated by kanjigen.go; DO NOT EDIT.\n\n")
+	fmt.Printf("package coding\n\n")
+	fmt.Printf("// kanjiTab maps a 13-bit Kanji mode value to its Unicode code point.\n")
+	fmt.Printf("// Values without a Shift JIS character map to 0.\n")
+	fmt.Printf("var kanjiTab = [8192]uint16{\n")
+	for i := 0; i < len(tab); i += 12 {
+		fmt.Printf("\t")
+		for j := i; j < i+12 && j < len(tab); j++ {
+			if j > i {
+				fmt.Printf(" ")
+			}
+			fmt.Printf("0x%04x,", tab[j])
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("}\n")
+}
@@ -0,0 +1,146 @@
+package coding
+
+import (
+	"fmt"
+
+	"github.com/cristalhq/qrcode/internal/gf256"
+)
+
+// A microVersion describes metadata associated with a Micro QR version.
+type microVersion struct {
+	bytes  int    // number of data and check bytes
+	half   bool   // the last data byte holds only 4 bits
+	check  [4]int // number of check bytes by level, 0 if there is no such level
+	symbol [4]int // symbol number of the format information by level
+}
+
+// mtab lists Micro QR versions M1 to M4, from ISO/IEC 18004, table 9.
+// M1 has error detection only, it is listed as level L.
+var mtab = [4]microVersion{
+	{5, true, [4]int{2, 0, 0, 0}, [4]int{0, 0, 0, 0}},     // M1
+	{10, false, [4]int{5, 6, 0, 0}, [4]int{1, 2, 0, 0}},   // M2
+	{17, true, [4]int{6, 8, 0, 0}, [4]int{3, 4, 0, 0}},    // M3
+	{24, false, [4]int{8, 10, 14, 0}, [4]int{5, 6, 7, 0}}, // M4
+}
+
+// microMasks maps Micro QR masks 0 to 3 to the QR masks they use.
+var microMasks = [4]Mask{1, 4, 6, 7}
+
+// microInvert reports whether Micro QR mask m inverts the pixel at (x, y).
+func (m Mask) microInvert(y, x int) bool {
+	return mfunc[microMasks[m]](y, x)
+}
+
+// numMasks returns the number of masks version v can use.
+func (v Version) numMasks() Mask {
+	if v.Micro() {
+		return 4
+	}
+	return 8
+}
+
+// microPlan creates a Plan for the given Micro QR version, level and mask.
+func (p *Plan) microPlan() {
+	v := p.Version
+	mt := &mtab[v-M1]
+	if mt.check[p.Level] == 0 {
+		panic(fmt.Sprintf("qr: invalid level %v for Micro QR version %v", p.Level, v))
+	}
+	if p.Mask < 0 || p.Mask > 3 {
+		panic(fmt.Sprintf("qr: invalid Micro QR mask %d", int(p.Mask)))
+	}
+
+	siz := 9 + 2*int(v-M1+1)
+	m := grid(siz)
+	p.Pixel = m
+
+	// Timing markers along the top row and the left column.
+	for i := range m {
+		p := Timing.Pixel()
+		if i&1 == 0 {
+			p |= Black
+		}
+		m[i][0] = p
+		m[0][i] = p
+	}
+
+	// The only position box.
+	posBox(m, 0, 0)
+
+	// Format pixels.
+	fb := uint32(mt.symbol[p.Level])<<12 | uint32(p.Mask)<<10
+	fb |= bchRemainder(fb)
+	invert := uint32(0x4445)
+	for i := uint(0); i < 15; i++ {
+		pix := Format.Pixel() + OffsetPixel(i)
+		if (fb>>i)&1 == 1 {
+			pix |= Black
+		}
+		if (invert>>i)&1 == 1 {
+			pix ^= Invert | Black
+		}
+		switch {
+		case i < 8:
+			m[i+1][8] = pix
+		default:
+			m[8][15-i] = pix
+		}
+	}
+
+	// Data and check pixels, in a single block.
+	// Check bytes start on a byte boundary, after the half data byte of M1 and M3.
+	nd := v.DataBits(p.Level)
+	nc := mt.check[p.Level] * 8
+	p.DataBytes = v.DataBytes(p.Level)
+	p.CheckBytes = mt.check[p.Level]
+	p.Blocks = 1
+
+	bits := make([]Pixel, 0, nd+nc)
+	for i := 0; i < nd; i++ {
+		bits = append(bits, Data.Pixel()|OffsetPixel(uint(i)))
+	}
+	for i := 0; i < nc; i++ {
+		bits = append(bits, Check.Pixel()|OffsetPixel(uint(p.DataBytes*8+i)))
+	}
+	p.place(bits)
+}
+
+// addMicroCheckBytes pads the data of a Micro QR code
+// and appends the check bytes.
+func (b *Bits) addMicroCheckBytes(v Version, l Level) {
+	n := v.DataBits(l)
+	if b.nbit > n {
+		panic("qr: too much data")
+	}
+
+	// Terminator, then zeros up to the byte boundary.
+	term := 3 + 2*int(v-M1)
+	if term > n-b.nbit {
+		term = n - b.nbit
+	}
+	b.Write(0, term)
+	pad := -b.nbit & 7
+	if pad > n-b.nbit {
+		pad = n - b.nbit
+	}
+	b.Write(0, pad)
+	for i := 0; b.nbit+8 <= n; i++ {
+		if i%2 == 0 {
+			b.Write(0xec, 8)
+		} else {
+			b.Write(0x11, 8)
+		}
+	}
+	// The 4-bit last data byte of M1 and M3 is padded with zeros.
+	b.Write(0, n-b.nbit)
+	b.Write(0, -b.nbit&7)
+
+	mt := &mtab[v-M1]
+	chk := make([]byte, mt.check[l])
+	gf256.NewRSEncoder(qrField, len(chk)).ECC(b.Bytes(), chk)
+	b.Append(chk)
+
+	if len(b.Bytes()) != mt.bytes {
+		panic("qr: internal error")
+	}
+}
@@ -0,0 +1,74 @@
+package coding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMicroCheckBytes(t *testing.T) {
+	// Example from ISO/IEC 18004, annex I.3.
+	var b Bits
+	Num("01234567").Encode(&b, M2)
+	b.AddCheckBytes(M2, L)
+	want := "4018acc300" + "860d22ae30"
+	if got := fmt.Sprintf("%x", b.Bytes()); got != want {
+		t.Errorf("have %s want %s", got, want)
+	}
+}
+
+func TestMicroPlan(t *testing.T) {
+	for v := M1; v <= M4; v++ {
+		for l := L; l <= Q; l++ {
+			if v.DataBits(l) == 0 {
+				continue
+			}
+			p := NewPlan(v, l, 0)
+			if siz := len(p.Pixel); siz != 2*int(v-M1+1)+9 {
+				t.Errorf("%v-%v: have size %d", v, l, siz)
+			}
+			n := 0
+			for _, row := range p.Pixel {
+				for _, pix := range row {
+					if r := pix.Role(); r == Data || r == Check {
+						n++
+					}
+					if pix.Role() == 0 {
+						t.Fatalf("%v-%v: pixel without a role", v, l)
+					}
+				}
+			}
+			if want := v.DataBits(l) + p.CheckBytes*8; n != want {
+				t.Errorf("%v-%v: have %d data and check pixels want %d", v, l, n, want)
+			}
+		}
+	}
+}
+
+func TestEncodeMicro(t *testing.T) {
+	opts := Options{MinVersion: M1, MaxVersion: M4, Mask: AutoMask}
+	testCases := []struct {
+		text    string
+		level   Level
+		version Version
+	}{
+		{"12345", L, M1},
+		{"ABC12", L, M2},
+		{"hello", L, M3},
+		{"hello, world", L, M4},
+		{"コード", M, M3},
+	}
+
+	for _, tc := range testCases {
+		c, err := Encode(nil, tc.text, tc.level, &opts)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.text, err)
+		}
+		if c.Version != tc.version {
+			t.Errorf("%q: have version %v want %v", tc.text, c.Version, tc.version)
+		}
+	}
+
+	if _, err := Encode(nil, "hello, world!!!!!!!!!!", L, &opts); err == nil {
+		t.Error("text must not fit into Micro QR")
+	}
+}
@@ -0,0 +1,150 @@
+package coding
+
+// Penalty weights from ISO/IEC 18004, section 7.8.3.
+const (
+	penaltyN1 = 3
+	penaltyN2 = 3
+	penaltyN3 = 40
+	penaltyN4 = 10
+)
+
+// Penalty returns the mask penalty score of the code.
+// It is the sum of the four ISO/IEC 18004 penalty rules:
+// runs of same-colored modules, 2x2 blocks, finder-like
+// sequences and the balance between dark and light modules.
+// The lower the score, the better the code scans.
+//
+// For Micro QR codes it is the negated evaluation score
+// of ISO/IEC 18004, section 7.8.3.2. It is 0 for rMQR codes,
+// which have a single mask.
+func (c *Code) Penalty() int {
+	if c.Version.Micro() {
+		return -c.microScore()
+	}
+	if c.Version.Rect() {
+		return 0
+	}
+	return c.penaltyRuns() + c.penaltyBlocks() + c.penaltyFinders() + c.penaltyBalance()
+}
+
+// microScore scores the dark modules along the right and the bottom
+// edges of a Micro QR code. The higher the score, the better.
+func (c *Code) microScore() int {
+	sum1, sum2 := 0, 0
+	for i := 1; i < c.Size; i++ {
+		if c.Black(c.Size-1, i) {
+			sum1++
+		}
+		if c.Black(i, c.Size-1) {
+			sum2++
+		}
+	}
+	if sum1 > sum2 {
+		sum1, sum2 = sum2, sum1
+	}
+	return sum1*16 + sum2
+}
+
+// penaltyRuns scores each row and column run of 5 or more
+// same-colored modules as N1 + (run length - 5).
+func (c *Code) penaltyRuns() int {
+	score := 0
+	for i := 0; i < c.Size; i++ {
+		rowRun, colRun := 1, 1
+		for j := 1; j < c.Size; j++ {
+			if c.Black(j, i) == c.Black(j-1, i) {
+				rowRun++
+			} else {
+				score += runPenalty(rowRun)
+				rowRun = 1
+			}
+			if c.Black(i, j) == c.Black(i, j-1) {
+				colRun++
+			} else {
+				score += runPenalty(colRun)
+				colRun = 1
+			}
+		}
+		score += runPenalty(rowRun) + runPenalty(colRun)
+	}
+	return score
+}
+
+func runPenalty(run int) int {
+	if run < 5 {
+		return 0
+	}
+	return penaltyN1 + run - 5
+}
+
+// penaltyBlocks scores every 2x2 block of same-colored modules.
+func (c *Code) penaltyBlocks() int {
+	score := 0
+	for y := 0; y+1 < c.Size; y++ {
+		for x := 0; x+1 < c.Size; x++ {
+			b := c.Black(x, y)
+			if c.Black(x+1, y) == b && c.Black(x, y+1) == b && c.Black(x+1, y+1) == b {
+				score += penaltyN2
+			}
+		}
+	}
+	return score
+}
+
+// penaltyFinders scores every dark-light-dark-dark-dark-light-dark
+// sequence that has 4 light modules on either side, in rows and columns.
+// Modules outside of the symbol count as light.
+func (c *Code) penaltyFinders() int {
+	score := 0
+	for i := 0; i < c.Size; i++ {
+		for j := 0; j+7 <= c.Size; j++ {
+			row := func(k int) bool { return c.Black(j+k, i) }
+			col := func(k int) bool { return c.Black(i, j+k) }
+			if finderLike(row) {
+				score += penaltyN3
+			}
+			if finderLike(col) {
+				score += penaltyN3
+			}
+		}
+	}
+	return score
+}
+
+// finderLike reports whether black(0..6) is 1011101
+// with black(-4..-1) or black(7..10) all light.
+func finderLike(black func(int) bool) bool {
+	for k, want := range [7]bool{true, false, true, true, true, false, true} {
+		if black(k) != want {
+			return false
+		}
+	}
+	light := func(from int) bool {
+		for k := from; k < from+4; k++ {
+			if black(k) {
+				return false
+			}
+		}
+		return true
+	}
+	return light(-4) || light(7)
+}
+
+// penaltyBalance scores N4 for each full 5% the proportion
+// of dark modules deviates from 50%.
+func (c *Code) penaltyBalance() int {
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				dark++
+			}
+		}
+	}
+	total := c.Size * c.Size
+	diff := dark*2 - total
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff * 10 / total * penaltyN4
+}
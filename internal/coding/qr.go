@@ -6,32 +6,173 @@ import (
 	"fmt"
 )
 
-func Encode(bitmap []byte, text string, level Level) (*Code, error) {
-	var enc Encoding
+// Options control which symbol Encode and EncodeData produce.
+type Options struct {
+	MinVersion Version // smallest version to use
+	MaxVersion Version // largest version to use
+	Mask       Mask    // mask to apply, or AutoMask
+	Boost      bool    // raise the level while the data fits the same version
+	MaxHeight  int     // largest height of rMQR versions, 0 for any
+}
+
+// AutoMask selects the mask with the lowest penalty score.
+const AutoMask Mask = -1
+
+var defaultOptions = Options{
+	MinVersion: MinVersion,
+	MaxVersion: MaxVersion,
+	Mask:       AutoMask,
+}
+
+// Encode returns an encoding of text at the given level.
+// The text is split into segments of the modes that take the fewest bits.
+// Nil opts selects any version and the best mask.
+func Encode(bitmap []byte, text string, level Level, opts *Options) (*Code, error) {
+	return encodeSegmented(bitmap, level, opts, func(v Version) Encoding {
+		if v.Micro() {
+			return Segment(text, v)
+		}
+		return withUTF8(Segment(text, v))
+	})
+}
+
+// EncodeGS1 returns an encoding of a GS1 element string at the given level.
+// Nil opts selects any version and the best mask.
+func EncodeGS1(bitmap []byte, data string, level Level, opts *Options) (*Code, error) {
+	return encodeSegmented(bitmap, level, opts, func(v Version) Encoding {
+		return SegmentGS1(data, v)
+	})
+}
+
+// encodeSegmented encodes data split into segments by segment
+// in the smallest version that fits it.
+func encodeSegmented(bitmap []byte, level Level, opts *Options, segment func(Version) Encoding) (*Code, error) {
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	// The optimal segmentation depends on the size of the
+	// character count fields, so try each size class in turn.
+	for _, class := range opts.classes() {
+		enc := segment(class[0])
+		if version, ok := fit(enc, level, class[0], class[1]); ok {
+			return encode(bitmap, version, level, enc, opts)
+		}
+	}
+	return nil, errTooLong
+}
+
+// classes returns the ranges of versions allowed by o that share
+// the sizes of the character count fields, from the smallest.
+// Each Micro QR and rMQR version is a class of its own,
+// rMQR versions are ordered by area.
+func (o *Options) classes() [][2]Version {
+	var classes [][2]Version
 	switch {
-	case Num(text).Check():
-		enc = Num(text)
-	case Alpha(text).Check():
-		enc = Alpha(text)
+	case o.MinVersion.Micro():
+		classes = [][2]Version{{M1, M1}, {M2, M2}, {M3, M3}, {M4, M4}}
+	case o.MinVersion.Rect():
+		for _, v := range rectOrder {
+			if o.MaxHeight == 0 || rtab[v-R7x43].height <= o.MaxHeight {
+				classes = append(classes, [2]Version{v, v})
+			}
+		}
 	default:
-		enc = String(text)
+		classes = [][2]Version{{MinVersion, 9}, {10, 26}, {27, MaxVersion}}
+	}
+	n := 0
+	for _, class := range classes {
+		if class[0] < o.MinVersion {
+			class[0] = o.MinVersion
+		}
+		if class[1] > o.MaxVersion {
+			class[1] = o.MaxVersion
+		}
+		if class[0] <= class[1] {
+			classes[n] = class
+			n++
+		}
+	}
+	return classes[:n]
+}
+
+// EncodeData returns an encoding of enc in the smallest version that fits it.
+// Nil opts selects any version and the best mask.
+func EncodeData(bitmap []byte, enc Encoding, level Level, opts *Options) (*Code, error) {
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	for _, class := range opts.classes() {
+		if version, ok := fit(enc, level, class[0], class[1]); ok {
+			return encode(bitmap, version, level, enc, opts)
+		}
 	}
+	return nil, errTooLong
+}
+
+var errTooLong = errors.New("text too long to encode as QR")
 
-	version := MinVersion
-	for {
-		if version > MaxVersion {
-			return nil, errors.New("text too long to encode as QR")
+// fit returns the smallest version between min and max that can hold enc.
+func fit(enc Encoding, level Level, min, max Version) (Version, bool) {
+	for version := min; version <= max; version++ {
+		if enc.Bits(version) <= version.DataBits(level) {
+			return version, true
 		}
-		if enc.Bits(version) <= version.DataBytes(level)*8 {
-			break
+	}
+	return 0, false
+}
+
+func encode(bitmap []byte, version Version, level Level, enc Encoding, opts *Options) (*Code, error) {
+	if opts.Boost {
+		for l := H; l > level; l-- {
+			if enc.Bits(version) <= version.DataBits(l) {
+				level = l
+				break
+			}
 		}
-		version++
 	}
-	return NewPlan(version, level, 0).EncodeInto(bitmap, enc)
+	if version.Rect() {
+		return NewPlan(version, level, rectMask).EncodeInto(bitmap, enc)
+	}
+	if opts.Mask == AutoMask {
+		return encodeBestMask(bitmap, version, level, enc)
+	}
+	if opts.Mask < 0 || opts.Mask > 7 {
+		return nil, fmt.Errorf("invalid mask %d", int(opts.Mask))
+	}
+	return NewPlan(version, level, opts.Mask).EncodeInto(bitmap, enc)
+}
+
+// encodeBestMask encodes enc with each of the 8 masks
+// and returns the code with the lowest penalty score.
+func encodeBestMask(bitmap []byte, version Version, level Level, enc Encoding) (*Code, error) {
+	var best *Code
+	var bestPenalty int
+	var scratch []byte
+	for mask := Mask(0); mask < version.numMasks(); mask++ {
+		c, err := NewPlan(version, level, mask).EncodeInto(scratch, enc)
+		if err != nil {
+			return nil, err
+		}
+		penalty := c.Penalty()
+		if best == nil || penalty < bestPenalty {
+			if best != nil {
+				scratch = best.Bitmap
+			}
+			best, bestPenalty = c, penalty
+		} else {
+			scratch = c.Bitmap
+		}
+	}
+	if bitmap != nil {
+		bitmap = bitmap[:len(best.Bitmap)]
+		copy(bitmap, best.Bitmap)
+		best.Bitmap = bitmap
+	}
+	return best, nil
 }
 
 // Encoding implements a QR data encoding scheme.
-// The implementations--Numeric, Alphanumeric, and String--specify
+// The implementations--Numeric, Alphanumeric, String and Kanji--specify
 // the character set and the mapping from UTF-8 to code bits.
 // The more restrictive the mode, the fewer code bits are needed.
 type Encoding interface {
@@ -40,17 +181,42 @@ type Encoding interface {
 	Encode(b *Bits, v Version)
 }
 
-// A Code is a square pixel grid.
+// A Code is a square pixel grid, or a rectangular one for rMQR codes.
 type Code struct {
-	Bitmap []byte // 1 is black, 0 is white
-	Size   int    // number of pixels on a side
-	Stride int    // number of bytes per row
+	Bitmap  []byte  // 1 is black, 0 is white
+	Size    int     // number of pixels on a side, the width for rMQR codes
+	Width   int     // number of pixels in a row
+	Height  int     // number of rows
+	Stride  int     // number of bytes per row
+	Version Version // version of the code
+	Level   Level   // error correction level
+	Mask    Mask    // mask applied to the data pixels
 }
 
-// func (c *Code) Black(x, y int) bool {
-// 	return 0 <= x && x < c.Size && 0 <= y && y < c.Size &&
-// 		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
-// }
+// Black reports whether the pixel at (x, y) is black.
+// Pixels outside of the code are white.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Width && 0 <= y && y < c.Height &&
+		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
+}
+
+// Transpose returns a copy of c with its rows and columns swapped,
+// as a code seen in a mirror is sampled.
+func (c *Code) Transpose() *Code {
+	t := *c
+	t.Width, t.Height = c.Height, c.Width
+	t.Size = t.Width
+	t.Stride = (t.Width + 7) &^ 7
+	t.Bitmap = make([]byte, t.Stride*t.Height)
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			if c.Black(y, x) {
+				t.Bitmap[y*t.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return &t
+}
 
 // A Plan describes how to construct a QR code
 // with a specific version, level, and mask.
@@ -73,9 +239,16 @@ func NewPlan(version Version, level Level, mask Mask) *Plan {
 		Level:   level,
 		Mask:    mask,
 	}
-	p.vplan()
-	p.fplan()
-	p.lplan()
+	switch {
+	case version.Micro():
+		p.microPlan()
+	case version.Rect():
+		p.rectPlan()
+	default:
+		p.vplan()
+		p.fplan()
+		p.lplan()
+	}
 	p.mplan()
 	return p
 }
@@ -92,22 +265,34 @@ func (p *Plan) EncodeInto(bitmap []byte, text Encoding) (*Code, error) {
 	var b Bits
 	text.Encode(&b, p.Version)
 
-	if b.Bits() > p.DataBytes*8 {
-		return nil, fmt.Errorf("cannot encode %d bits into %d-bit code", b.Bits(), p.DataBytes*8)
+	if n := p.Version.DataBits(p.Level); b.Bits() > n {
+		return nil, fmt.Errorf("cannot encode %d bits into %d-bit code", b.Bits(), n)
 	}
 	b.AddCheckBytes(p.Version, p.Level)
-	bytes := b.Bytes()
+	return p.LayoutInto(bitmap, b.Bytes()), nil
+}
 
-	// Now we have the checksum bytes and the data bytes.
-	// Construct the actual code.
+// LayoutInto returns the code with the given codewords, the data bytes
+// followed by the check bytes, laid out by p. It reuses bitmap for
+// the code pixels when possible.
+func (p *Plan) LayoutInto(bitmap, codewords []byte) *Code {
+	w, h := len(p.Pixel[0]), len(p.Pixel)
 	c := &Code{
-		Size:   len(p.Pixel),
-		Stride: (len(p.Pixel) + 7) &^ 7,
+		Size:    w,
+		Width:   w,
+		Height:  h,
+		Stride:  (w + 7) &^ 7,
+		Version: p.Version,
+		Level:   p.Level,
+		Mask:    p.Mask,
 	}
 	if bitmap == nil {
-		bitmap = make([]byte, c.Stride*c.Size)
+		bitmap = make([]byte, c.Stride*c.Height)
+	}
+	c.Bitmap = bitmap[:c.Stride*c.Height]
+	for i := range c.Bitmap {
+		c.Bitmap[i] = 0
 	}
-	c.Bitmap = bitmap[:c.Stride*c.Size]
 
 	crow := c.Bitmap
 	for _, row := range p.Pixel {
@@ -115,7 +300,7 @@ func (p *Plan) EncodeInto(bitmap []byte, text Encoding) (*Code, error) {
 			switch pix.Role() {
 			case Data, Check:
 				o := pix.Offset()
-				if bytes[o/8]&(1<<(7-o&7)) != 0 {
+				if codewords[o/8]&(1<<(7-o&7)) != 0 {
 					pix ^= Black
 				}
 			}
@@ -125,7 +310,22 @@ func (p *Plan) EncodeInto(bitmap []byte, text Encoding) (*Code, error) {
 		}
 		crow = crow[c.Stride:]
 	}
-	return c, nil
+	return c
+}
+
+// BlockOf returns the index of the block of the codeword at index k
+// in the codewords laid out by p, the data bytes followed by the check bytes.
+func (p *Plan) BlockOf(k int) int {
+	if k >= p.DataBytes {
+		return (k - p.DataBytes) / (p.CheckBytes / p.Blocks)
+	}
+	// The last blocks have an extra data byte.
+	nd, extra := p.DataBytes/p.Blocks, p.DataBytes%p.Blocks
+	short := (p.Blocks - extra) * nd
+	if k < short {
+		return k / nd
+	}
+	return p.Blocks - extra + (k-short)/(nd+1)
 }
 
 func grid(siz int) [][]Pixel {
@@ -212,14 +412,7 @@ func (p *Plan) fplan() {
 	// Format pixels.
 	fb := uint32(p.Level^1) << 13 // level: L=01, M=00, Q=11, H=10
 	fb |= uint32(p.Mask) << 10    // mask
-	const formatPoly = 0x537
-	rem := fb
-	for i := 14; i >= 10; i-- {
-		if rem&(1<<uint(i)) != 0 {
-			rem ^= formatPoly << uint(i-10)
-		}
-	}
-	fb |= rem
+	fb |= bchRemainder(fb)
 	invert := uint32(0x5412)
 	siz := len(p.Pixel)
 	for i := uint(0); i < 15; i++ {
@@ -251,20 +444,42 @@ func (p *Plan) fplan() {
 	}
 }
 
+// bchRemainder returns the BCH(15,5) error correction bits
+// of the format information data bits fb<<10.
+func bchRemainder(fb uint32) uint32 {
+	const formatPoly = 0x537
+	rem := fb
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= formatPoly << uint(i-10)
+		}
+	}
+	return rem
+}
+
 // lplan edits a version-only Plan to add information
 // about the error correction levels.
 func (p *Plan) lplan() {
-	v := p.Version
-	l := p.Level
+	bits := p.blockPixels(p.Version.blocks(p.Level))
+
+	rem := make([]Pixel, 7)
+	for i := range rem {
+		rem[i] = Extra.Pixel()
+	}
+	p.place(append(bits, rem...))
+}
 
-	nblock := vtab[v].level[l].nblock
-	ne := vtab[v].level[l].check
-	nde := (vtab[v].bytes - ne*nblock) / nblock
-	extra := (vtab[v].bytes - ne*nblock) % nblock
+// blockPixels returns the data and check pixels of a code with bytes
+// data and check bytes, split into nblock blocks of ne check bytes each,
+// in the order they are placed: the first byte of each block,
+// then the second byte, and so on. Then the check bytes.
+func (p *Plan) blockPixels(bytes, nblock, ne int) []Pixel {
+	nde := (bytes - ne*nblock) / nblock
+	extra := (bytes - ne*nblock) % nblock
 	dataBits := (nde*nblock + extra) * 8
 	checkBits := ne * nblock * 8
 
-	p.DataBytes = vtab[v].bytes - ne*nblock
+	p.DataBytes = bytes - ne*nblock
 	p.CheckBytes = ne * nblock
 	p.Blocks = nblock
 
@@ -317,48 +532,49 @@ func (p *Plan) lplan() {
 	if len(dst) != 0 {
 		panic("qr: dst math")
 	}
+	return bits
+}
 
-	// Sweep up pair of columns,
-	// then down, assigning to right then left pixel.
-	// Repeat.
-	// See Figure 2 of http://www.pclviewer.com/rs2/qrtopology.htm
-	siz := len(p.Pixel)
-	rem := make([]Pixel, 7)
-	for i := range rem {
-		rem[i] = Extra.Pixel()
+// place assigns src to the pixels that have no role yet.
+// It sweeps up a pair of columns, then down the next one,
+// assigning to right then left pixel. And so on.
+// See Figure 2 of http://www.pclviewer.com/rs2/qrtopology.htm
+func (p *Plan) place(src []Pixel) {
+	w, h := len(p.Pixel[0]), len(p.Pixel)
+	start := w - 1
+	if p.Version.Rect() { // right timing strip
+		start--
 	}
-	src := append(bits, rem...)
-	for x := siz; x > 0; {
-		for y := siz - 1; y >= 0; y-- {
-			if p.Pixel[y][x-1].Role() == 0 {
-				p.Pixel[y][x-1], src = src[0], src[1:]
-			}
-			if p.Pixel[y][x-2].Role() == 0 {
-				p.Pixel[y][x-2], src = src[0], src[1:]
-			}
-		}
-		x -= 2
-		if x == 7 { // vertical timing strip
+	up := true
+	for x := start; x > 0; x -= 2 {
+		if x == 6 && !p.Version.Micro() && !p.Version.Rect() { // vertical timing strip
 			x--
 		}
-		for y := 0; y < siz; y++ {
-			if p.Pixel[y][x-1].Role() == 0 {
-				p.Pixel[y][x-1], src = src[0], src[1:]
+		for i := 0; i < h; i++ {
+			y := i
+			if up {
+				y = h - 1 - i
 			}
-			if p.Pixel[y][x-2].Role() == 0 {
-				p.Pixel[y][x-2], src = src[0], src[1:]
+			for _, x := range [2]int{x, x - 1} {
+				if p.Pixel[y][x].Role() == 0 {
+					p.Pixel[y][x], src = src[0], src[1:]
+				}
 			}
 		}
-		x -= 2
+		up = !up
 	}
 }
 
 // mplan edits a version+level-only Plan to add the mask.
 func (p *Plan) mplan() {
+	invert := p.Mask.Invert
+	if p.Version.Micro() {
+		invert = p.Mask.microInvert
+	}
 	for y, row := range p.Pixel {
 		for x, pix := range row {
 			r := pix.Role()
-			if (r == Data || r == Check || r == Extra) && p.Mask.Invert(y, x) {
+			if (r == Data || r == Check || r == Extra) && invert(y, x) {
 				row[x] ^= Black | Invert
 			}
 		}
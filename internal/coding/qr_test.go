@@ -0,0 +1,65 @@
+package coding
+
+import "testing"
+
+func TestEncodeBestMask(t *testing.T) {
+	text := "https://github.com/cristalhq/qrcode"
+	c, err := Encode(nil, text, M, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := Version((c.Size - 17) / 4)
+	for mask := Mask(0); mask < 8; mask++ {
+		other, err := NewPlan(version, M, mask).Encode(String(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other.Penalty() < c.Penalty() {
+			t.Errorf("mask %d has penalty %d, lower than chosen mask %d with %d",
+				mask, other.Penalty(), c.Mask, c.Penalty())
+		}
+	}
+}
+
+func TestPenaltyBalance(t *testing.T) {
+	c := &Code{Size: 8, Width: 8, Height: 8, Stride: 8, Bitmap: make([]byte, 64)}
+	if got := c.penaltyBalance(); got != 100 {
+		t.Errorf("all white penaltyBalance() = %d, want 100", got)
+	}
+	for y := 0; y < 4; y++ {
+		c.Bitmap[y*c.Stride] = 0xff
+	}
+	if got := c.penaltyBalance(); got != 0 {
+		t.Errorf("half black penaltyBalance() = %d, want 0", got)
+	}
+}
+
+func TestCorrectable(t *testing.T) {
+	// Error correction capacities from ISO/IEC 18004, table 9.
+	testCases := []struct {
+		v    Version
+		l    Level
+		want int
+	}{
+		{M1, L, 0},
+		{M2, L, 1},
+		{M2, M, 2},
+		{M3, L, 2},
+		{M3, M, 4},
+		{M4, L, 3},
+		{M4, Q, 7},
+		{1, L, 2},
+		{1, M, 4},
+		{1, Q, 6},
+		{1, H, 8},
+		{2, L, 4},
+		{2, M, 8},
+		{40, H, 15},
+	}
+	for _, tc := range testCases {
+		if have := tc.v.Correctable(tc.l); have != tc.want {
+			t.Errorf("%v-%v: have %d want %d", tc.v, tc.l, have, tc.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package coding
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Rectangular Micro QR (rMQR) versions, named by height and width in pixels.
+const (
+	R7x43 Version = M4 + 1 + iota
+	R7x59
+	R7x77
+	R7x99
+	R7x139
+	R9x43
+	R9x59
+	R9x77
+	R9x99
+	R9x139
+	R11x27
+	R11x43
+	R11x59
+	R11x77
+	R11x99
+	R11x139
+	R13x27
+	R13x43
+	R13x59
+	R13x77
+	R13x99
+	R13x139
+	R15x43
+	R15x59
+	R15x77
+	R15x99
+	R15x139
+	R17x43
+	R17x59
+	R17x77
+	R17x99
+	R17x139
+)
+
+// Rect reports whether v is an rMQR version.
+func (v Version) Rect() bool {
+	return R7x43 <= v && v <= R17x139
+}
+
+// A rectVersion describes metadata associated with an rMQR version.
+type rectVersion struct {
+	width  int
+	height int
+	bytes  int           // number of data and check bytes
+	data   [2]int        // number of data bytes at levels M and H
+	nblock [2]int        // number of blocks at levels M and H
+	count  [numModes]int // number of bits of the character count by mode
+}
+
+// rtab lists rMQR versions R7x43 to R17x139, from ISO/IEC 23941, tables 3, 6 and 8.
+var rtab = [32]rectVersion{
+	{43, 7, 13, [2]int{6, 3}, [2]int{1, 1}, [numModes]int{3, 3, 4, 2}},       // R7x43
+	{59, 7, 21, [2]int{12, 7}, [2]int{1, 1}, [numModes]int{4, 5, 5, 3}},      // R7x59
+	{77, 7, 32, [2]int{20, 10}, [2]int{1, 1}, [numModes]int{5, 5, 6, 4}},     // R7x77
+	{99, 7, 44, [2]int{28, 14}, [2]int{1, 1}, [numModes]int{5, 6, 7, 5}},     // R7x99
+	{139, 7, 68, [2]int{44, 24}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},    // R7x139
+	{43, 9, 21, [2]int{12, 7}, [2]int{1, 1}, [numModes]int{4, 5, 5, 3}},      // R9x43
+	{59, 9, 33, [2]int{21, 11}, [2]int{1, 1}, [numModes]int{5, 5, 6, 4}},     // R9x59
+	{77, 9, 49, [2]int{31, 17}, [2]int{1, 2}, [numModes]int{5, 6, 7, 5}},     // R9x77
+	{99, 9, 66, [2]int{42, 22}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},     // R9x99
+	{139, 9, 99, [2]int{63, 33}, [2]int{2, 3}, [numModes]int{6, 7, 8, 6}},    // R9x139
+	{27, 11, 15, [2]int{7, 5}, [2]int{1, 1}, [numModes]int{3, 4, 4, 2}},      // R11x27
+	{43, 11, 31, [2]int{19, 11}, [2]int{1, 1}, [numModes]int{5, 5, 6, 4}},    // R11x43
+	{59, 11, 47, [2]int{31, 15}, [2]int{1, 2}, [numModes]int{5, 6, 7, 5}},    // R11x59
+	{77, 11, 67, [2]int{43, 23}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},    // R11x77
+	{99, 11, 89, [2]int{57, 29}, [2]int{2, 2}, [numModes]int{6, 7, 8, 6}},    // R11x99
+	{139, 11, 132, [2]int{84, 42}, [2]int{2, 3}, [numModes]int{7, 7, 8, 6}},  // R11x139
+	{27, 13, 21, [2]int{12, 7}, [2]int{1, 1}, [numModes]int{4, 5, 5, 3}},     // R13x27
+	{43, 13, 41, [2]int{27, 13}, [2]int{1, 1}, [numModes]int{5, 6, 6, 5}},    // R13x43
+	{59, 13, 60, [2]int{38, 20}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},    // R13x59
+	{77, 13, 85, [2]int{53, 29}, [2]int{2, 2}, [numModes]int{6, 7, 7, 6}},    // R13x77
+	{99, 13, 113, [2]int{73, 35}, [2]int{2, 3}, [numModes]int{7, 7, 8, 6}},   // R13x99
+	{139, 13, 166, [2]int{106, 54}, [2]int{3, 4}, [numModes]int{7, 8, 8, 7}}, // R13x139
+	{43, 15, 51, [2]int{33, 15}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},    // R15x43
+	{59, 15, 74, [2]int{48, 26}, [2]int{1, 2}, [numModes]int{6, 7, 7, 5}},    // R15x59
+	{77, 15, 103, [2]int{67, 31}, [2]int{2, 3}, [numModes]int{7, 7, 8, 6}},   // R15x77
+	{99, 15, 136, [2]int{88, 48}, [2]int{2, 4}, [numModes]int{7, 7, 8, 6}},   // R15x99
+	{139, 15, 199, [2]int{127, 69}, [2]int{3, 5}, [numModes]int{7, 8, 9, 7}}, // R15x139
+	{43, 17, 61, [2]int{39, 21}, [2]int{1, 2}, [numModes]int{6, 6, 7, 5}},    // R17x43
+	{59, 17, 88, [2]int{56, 28}, [2]int{2, 2}, [numModes]int{6, 7, 8, 6}},    // R17x59
+	{77, 17, 122, [2]int{78, 38}, [2]int{2, 3}, [numModes]int{7, 7, 8, 6}},   // R17x77
+	{99, 17, 160, [2]int{100, 56}, [2]int{3, 4}, [numModes]int{7, 8, 8, 6}},  // R17x99
+	{139, 17, 232, [2]int{152, 76}, [2]int{4, 6}, [numModes]int{8, 8, 9, 7}}, // R17x139
+}
+
+// rectAlign lists the columns of the alignment box centers by rMQR width.
+var rectAlign = map[int][]int{
+	27:  nil,
+	43:  {21},
+	59:  {19, 39},
+	77:  {25, 51},
+	99:  {23, 49, 75},
+	139: {27, 55, 83, 111},
+}
+
+// rectMask is the only mask rMQR codes use.
+const rectMask Mask = 4
+
+// rectOrder lists the rMQR versions by area, and by height for equal areas.
+var rectOrder = func() []Version {
+	vs := make([]Version, 0, len(rtab))
+	for v := R7x43; v <= R17x139; v++ {
+		vs = append(vs, v)
+	}
+	sort.SliceStable(vs, func(i, j int) bool {
+		a, b := &rtab[vs[i]-R7x43], &rtab[vs[j]-R7x43]
+		return a.width*a.height < b.width*b.height
+	})
+	return vs
+}()
+
+// rectLevel returns the index of level l in rtab, -1 if rMQR has no such level.
+func rectLevel(l Level) int {
+	switch l {
+	case M:
+		return 0
+	case H:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// rectPlan creates a Plan for the given rMQR version and level.
+func (p *Plan) rectPlan() {
+	v := p.Version
+	rt := &rtab[v-R7x43]
+	lev := rectLevel(p.Level)
+	if lev < 0 {
+		panic(fmt.Sprintf("qr: invalid level %v for rMQR version %v", p.Level, v))
+	}
+	if p.Mask != rectMask {
+		panic(fmt.Sprintf("qr: invalid rMQR mask %d", int(p.Mask)))
+	}
+
+	w, h := rt.width, rt.height
+	m := make([][]Pixel, h)
+	pix := make([]Pixel, w*h)
+	for i := range m {
+		m[i], pix = pix[:w], pix[w:]
+	}
+	p.Pixel = m
+
+	// Timing markers along all four edges (overwritten by boxes).
+	for x := 0; x < w; x++ {
+		p := Timing.Pixel()
+		if x&1 == 0 {
+			p |= Black
+		}
+		m[0][x] = p
+		m[h-1][x] = p
+	}
+	for y := 0; y < h; y++ {
+		p := Timing.Pixel()
+		if y&1 == 0 {
+			p |= Black
+		}
+		m[y][0] = p
+		m[y][w-1] = p
+	}
+
+	// Position box at the top left, sub-position box at the bottom right.
+	pos := Position.Pixel()
+	for dy := 0; dy < 7; dy++ {
+		for dx := 0; dx < 7; dx++ {
+			p := pos
+			if dx == 0 || dx == 6 || dy == 0 || dy == 6 || 2 <= dx && dx <= 4 && 2 <= dy && dy <= 4 {
+				p |= Black
+			}
+			m[dy][dx] = p
+		}
+	}
+	for dy := 0; dy < 5; dy++ {
+		for dx := 0; dx < 5; dx++ {
+			p := pos
+			if dx == 0 || dx == 4 || dy == 0 || dy == 4 || dx == 2 && dy == 2 {
+				p |= Black
+			}
+			m[h-5+dy][w-5+dx] = p
+		}
+	}
+
+	// Corner patterns at the bottom left and the top right.
+	if h > 7 {
+		m[h-2][0] = pos | Black
+		m[h-2][1] = pos
+		m[h-1][1] = pos | Black
+	}
+	m[0][w-2] = pos | Black
+	m[1][w-2] = pos
+	m[1][w-1] = pos | Black
+
+	// White border of the position box.
+	for y := 0; y < 7; y++ {
+		m[y][7] = pos
+	}
+	if h > 7 {
+		for x := 0; x < 8; x++ {
+			m[7][x] = pos
+		}
+	}
+
+	// Alignment boxes at the top and the bottom edges,
+	// joined by a vertical timing pattern.
+	align := Alignment.Pixel()
+	for _, x := range rectAlign[w] {
+		for y := 0; y < h; y++ {
+			p := align
+			if y&1 == 0 {
+				p |= Black
+			}
+			m[y][x] = p
+		}
+		for _, y := range [2]int{1, h - 2} {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					p := align
+					if dx != 0 || dy != 0 {
+						p |= Black
+					}
+					m[y+dy][x+dx] = p
+				}
+			}
+		}
+	}
+
+	// Format pixels.
+	fb := uint32(v-R7x43) | uint32(lev)<<5
+	fb = fb<<12 | rectBCHRemainder(fb<<12)
+	for i := uint(0); i < 18; i++ {
+		left := Format.Pixel() + OffsetPixel(i)
+		if (fb>>i)&1 == 1 {
+			left |= Black
+		}
+		right := left
+		if (0x1fab2>>i)&1 == 1 {
+			left ^= Invert | Black
+		}
+		if (0x20a7b>>i)&1 == 1 {
+			right ^= Invert | Black
+		}
+		if i < 15 {
+			m[1+int(i)%5][8+int(i)/5] = left
+			m[h-6+int(i)%5][w-8+int(i)/5] = right
+		} else {
+			m[1+int(i)-15][11] = left
+			m[h-6][w-5+int(i)-15] = right
+		}
+	}
+
+	// Data and check pixels, interleaved the same way as QR codes.
+	// The pixels left over are remainder bits.
+	bits := p.blockPixels(v.blocks(p.Level))
+	free := 0
+	for _, row := range m {
+		for _, pix := range row {
+			if pix.Role() == 0 {
+				free++
+			}
+		}
+	}
+	for len(bits) < free {
+		bits = append(bits, Extra.Pixel())
+	}
+	p.place(bits)
+}
+
+// rectBCHRemainder returns the BCH(18,6) error correction bits
+// of the rMQR format information data bits fb<<12.
+func rectBCHRemainder(fb uint32) uint32 {
+	const formatPoly = 0x1f25
+	rem := fb
+	for i := 17; i >= 12; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= formatPoly << uint(i-12)
+		}
+	}
+	return rem
+}
@@ -0,0 +1,123 @@
+package coding
+
+import "testing"
+
+func TestRectPlan(t *testing.T) {
+	for v := R7x43; v <= R17x139; v++ {
+		rt := &rtab[v-R7x43]
+		for _, l := range []Level{M, H} {
+			p := NewPlan(v, l, rectMask)
+			if w, h := len(p.Pixel[0]), len(p.Pixel); w != rt.width || h != rt.height {
+				t.Errorf("%v-%v: have size %dx%d", v, l, h, w)
+			}
+			n, extra := 0, 0
+			for _, row := range p.Pixel {
+				for _, pix := range row {
+					switch pix.Role() {
+					case Data, Check:
+						n++
+					case Extra:
+						extra++
+					case 0:
+						t.Fatalf("%v-%v: pixel without a role", v, l)
+					}
+				}
+			}
+			if n != rt.bytes*8 || extra >= 8 {
+				t.Errorf("%v-%v: have %d data and check pixels and %d extra, want %d", v, l, n, extra, rt.bytes*8)
+			}
+		}
+	}
+}
+
+func TestRectAlign(t *testing.T) {
+	// Alignment pattern centers from ISO/IEC 23941, table 2.
+	centers := map[int][]int{
+		27:  nil,
+		43:  {21},
+		59:  {19, 39},
+		77:  {25, 51},
+		99:  {23, 49, 75},
+		139: {27, 55, 83, 111},
+	}
+	for v := R7x43; v <= R17x139; v++ {
+		p := NewPlan(v, M, rectMask)
+		w, h := rtab[v-R7x43].width, rtab[v-R7x43].height
+		n := 0
+		for _, row := range p.Pixel {
+			for _, pix := range row {
+				if pix.Role() == Alignment {
+					n++
+				}
+			}
+		}
+		if want := len(centers[w]) * (h + 12); n != want {
+			t.Errorf("%v: have %d alignment pixels want %d", v, n, want)
+		}
+		for _, x := range centers[w] {
+			for y := 0; y < h; y++ {
+				// The boxes are dark but for their centers, rows 1 and h-2,
+				// and the timing pattern between them starts dark.
+				pix := p.Pixel[y][x]
+				black := y != 1 && y != h-2 && (y <= 2 || y >= h-3 || y%2 == 0)
+				if pix.Role() != Alignment || (pix&Black != 0) != black {
+					t.Fatalf("%v: have %v at %d,%d", v, pix, x, y)
+				}
+				for _, dx := range []int{-1, 1} {
+					if box := y <= 2 || y >= h-3; box && p.Pixel[y][x+dx] != Alignment.Pixel()|Black {
+						t.Fatalf("%v: have %v at %d,%d", v, p.Pixel[y][x+dx], x+dx, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRectFormat(t *testing.T) {
+	// Values from ISO/IEC 23941, annex C.
+	testCases := []struct {
+		fb   uint32
+		want uint32
+	}{
+		{0, 0x1fab2},
+		{1, 0x1e597},
+	}
+	for _, tc := range testCases {
+		fb := tc.fb<<12 | rectBCHRemainder(tc.fb<<12)
+		if got := fb ^ 0x1fab2; got != tc.want {
+			t.Errorf("format %d: have %#x want %#x", tc.fb, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeRect(t *testing.T) {
+	opts := Options{MinVersion: R7x43, MaxVersion: R17x139}
+	testCases := []struct {
+		text      string
+		level     Level
+		maxHeight int
+		version   Version
+	}{
+		{"12345", M, 0, R11x27},
+		{"12345", M, 7, R7x43},
+		{"HELLO WORLD", M, 0, R13x27},
+		{"HELLO WORLD", H, 0, R11x43},
+		{"HELLO WORLD", H, 7, R7x77},
+		{"https://example.com/", M, 0, R9x59},
+	}
+
+	for _, tc := range testCases {
+		opts.MaxHeight = tc.maxHeight
+		c, err := Encode(nil, tc.text, tc.level, &opts)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.text, err)
+		}
+		if c.Version != tc.version || c.Mask != rectMask {
+			t.Errorf("%q: have version %v mask %d want %v", tc.text, c.Version, c.Mask, tc.version)
+		}
+		rt := &rtab[tc.version-R7x43]
+		if c.Width != rt.width || c.Height != rt.height {
+			t.Errorf("%q: have size %dx%d", tc.text, c.Height, c.Width)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package coding
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A mode identifies one of the data encodings a segment may use.
+type mode int
+
+const (
+	modeString mode = iota
+	modeAlpha
+	modeNum
+	modeKanji
+	numModes
+)
+
+// Segment splits text into a sequence of Num, Alpha, String and Kanji
+// segments that takes the fewest bits for versions of the same size
+// class as v. It uses the dynamic programming method of ISO/IEC 18004,
+// annex J: for every character and every mode it keeps the cheapest
+// encoding of the text so far that ends in that mode.
+func Segment(text string, v Version) Encoding {
+	return segment(text, v, false)
+}
+
+// SegmentGS1 is like Segment for a GS1 element string, in which the GS
+// character separates the elements. It returns the segments prefixed with
+// FNC1 in the first position. In alphanumeric segments GS is written
+// as % and % is escaped as %%, as ISO/IEC 18004, section 7.4.8 specifies.
+func SegmentGS1(data string, v Version) Encoding {
+	enc := segment(data, v, true)
+	if segs, ok := enc.(Segments); ok {
+		return append(Segments{FNC1{}}, segs...)
+	}
+	return Segments{FNC1{}, enc}
+}
+
+// GS is the ASCII group separator that ends variable-length GS1 elements.
+const GS = '\x1d'
+
+func segment(text string, v Version, gs1 bool) Encoding {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return String("")
+	}
+
+	// Costs are kept in sixths of a bit, so that numeric (10/3 bits)
+	// and alphanumeric (11/2 bits) characters have integer costs.
+	var head [numModes]int
+	for m := range head {
+		head[m] = v.headerBits(mode(m)) * 6
+	}
+
+	const none = -1
+	// from[i][m] is the mode of character i in the cheapest
+	// encoding of runes[:i+1] whose next character is in mode m.
+	from := make([][numModes]mode, len(runes))
+	cost := head
+	for i, c := range runes {
+		var cur [numModes]int
+		for m := range cur {
+			cur[m] = none
+			from[i][m] = none
+		}
+
+		cur[modeString] = cost[modeString] + utf8.RuneLen(c)*8*6
+		from[i][modeString] = modeString
+		switch {
+		case gs1 && c == '%':
+			cur[modeAlpha] = cost[modeAlpha] + 2*33
+			from[i][modeAlpha] = modeAlpha
+		case gs1 && c == GS, strings.ContainsRune(alphabet, c):
+			cur[modeAlpha] = cost[modeAlpha] + 33
+			from[i][modeAlpha] = modeAlpha
+		}
+		if '0' <= c && c <= '9' {
+			cur[modeNum] = cost[modeNum] + 20
+			from[i][modeNum] = modeNum
+		}
+		if _, ok := kanjiValue(c); ok {
+			cur[modeKanji] = cost[modeKanji] + 78
+			from[i][modeKanji] = modeKanji
+		}
+
+		// Switch modes after this character: finish the current
+		// segment on a whole bit and pay the header of the next one.
+		for to := mode(0); to < numModes; to++ {
+			for m := mode(0); m < numModes; m++ {
+				if cur[m] == none || from[i][m] != m {
+					continue
+				}
+				c := (cur[m]+5)/6*6 + head[to]
+				if cur[to] == none || c < cur[to] {
+					cur[to] = c
+					from[i][to] = m
+				}
+			}
+		}
+		cost = cur
+	}
+
+	end := mode(none)
+	for m := mode(0); m < numModes; m++ {
+		if from[len(runes)-1][m] == m && (end == none || cost[m] < cost[end]) {
+			end = m
+		}
+	}
+
+	// Trace back the mode of every character.
+	modes := make([]mode, len(runes))
+	for i := len(runes) - 1; i >= 0; i-- {
+		end = from[i][end]
+		modes[i] = end
+	}
+
+	var segs Segments
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || modes[i] != modes[start] {
+			segs = append(segs, newSegment(string(runes[start:i]), modes[start], gs1))
+			start = i
+		}
+	}
+	if len(segs) == 1 {
+		return segs[0]
+	}
+	return segs
+}
+
+func newSegment(text string, m mode, gs1 bool) Encoding {
+	switch m {
+	case modeAlpha:
+		if gs1 {
+			text = strings.ReplaceAll(text, "%", "%%")
+			text = strings.ReplaceAll(text, string(GS), "%")
+		}
+		return Alpha(text)
+	case modeNum:
+		return Num(text)
+	case modeKanji:
+		return Kanji(text)
+	default:
+		return String(text)
+	}
+}
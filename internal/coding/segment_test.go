@@ -0,0 +1,63 @@
+package coding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSegment(t *testing.T) {
+	testCases := []struct {
+		text string
+		want string
+	}{
+		{"", "String(``)"},
+		{"0123456789", "Num(`0123456789`)"},
+		{"HELLO WORLD", "Alpha(`HELLO WORLD`)"},
+		{"hello, world", "String(`hello, world`)"},
+		{"コード", "Kanji(`コード`)"},
+		{"123456789012345678901234567890a", "[Num(`123456789012345678901234567890`) String(`a`)]"},
+		{"a1b", "String(`a1b`)"},
+		{"INV-2023-000123 https://example.com/i", "[Alpha(`INV-2023-000123 `) String(`https://example.com/i`)]"},
+		{"QRコード", "[Alpha(`QR`) Kanji(`コード`)]"},
+	}
+
+	for _, tc := range testCases {
+		if got := fmt.Sprint(Segment(tc.text, 1)); got != tc.want {
+			t.Errorf("Segment(%q) = %s, want %s", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSegmentShorter(t *testing.T) {
+	texts := []string{
+		"0123456789abcdef0123456789",
+		"HTTPS://EXAMPLE.COM/0123456789012345678901234567890",
+		"Номер 1234567890123 QRコード",
+	}
+	for _, text := range texts {
+		for _, v := range []Version{1, 10, 27} {
+			seg := Segment(text, v).Bits(v)
+			if str := String(text).Bits(v); seg > str {
+				t.Errorf("Segment(%q, %d) takes %d bits, more than String with %d", text, v, seg, str)
+			}
+		}
+	}
+}
+
+func TestSegmentGS1(t *testing.T) {
+	testCases := []struct {
+		data string
+		want string
+	}{
+		{"0104012345123456", "[FNC1 Num(`0104012345123456`)]"},
+		{"01040123451234561710123110ABC123\x1d2112345", "[FNC1 Num(`01040123451234561710123110`) Alpha(`ABC123%`) Num(`2112345`)]"},
+		{"10AB%CD", "[FNC1 Alpha(`10AB%%CD`)]"},
+		{"10abcdefgh\x1d21x", `[FNC1 String("10abcdefgh\x1d21x")]`},
+	}
+
+	for _, tc := range testCases {
+		if got := fmt.Sprint(SegmentGS1(tc.data, 1)); got != tc.want {
+			t.Errorf("SegmentGS1(%q) = %s, want %s", tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package coding
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxSymbols is the largest number of symbols
+// a Structured Append sequence can have.
+const MaxSymbols = 16
+
+// StructuredAppend is the header of a symbol that is a part
+// of a Structured Append sequence. Readers put the data of
+// all the symbols with the same parity back together in order.
+type StructuredAppend struct {
+	Index  int  // position of the symbol, from 0
+	Total  int  // number of symbols, up to MaxSymbols
+	Parity byte // XOR of all the data bytes of the sequence, as Parity
+}
+
+func (s StructuredAppend) String() string {
+	return fmt.Sprintf("StructuredAppend(%d/%d, %#02x)", s.Index+1, s.Total, s.Parity)
+}
+
+func (s StructuredAppend) Check() bool {
+	return 0 < s.Total && s.Total <= MaxSymbols && 0 <= s.Index && s.Index < s.Total
+}
+
+func (s StructuredAppend) Bits(v Version) int {
+	if v.Micro() || v.Rect() {
+		return noFit
+	}
+	return 4 + 4 + 4 + 8
+}
+
+func (s StructuredAppend) Encode(b *Bits, v Version) {
+	b.Write(3, 4)
+	b.Write(uint(s.Index), 4)
+	b.Write(uint(s.Total-1), 4)
+	b.Write(uint(s.Parity), 8)
+}
+
+// Parity returns the Structured Append parity of the data encoded by enc:
+// the XOR of its data bytes, with Kanji characters in Shift JIS.
+// Headers such as ECI and Structured Append ones have no data bytes.
+func Parity(enc Encoding) byte {
+	var p byte
+	xor := func(s string) {
+		for i := 0; i < len(s); i++ {
+			p ^= s[i]
+		}
+	}
+	switch e := enc.(type) {
+	case Segments:
+		for _, s := range e {
+			p ^= Parity(s)
+		}
+	case Kanji:
+		for _, c := range e {
+			w, _ := kanjiValue(c)
+			sjis := shiftJIS(w)
+			p ^= byte(sjis>>8) ^ byte(sjis)
+		}
+	case String:
+		xor(string(e))
+	case Alpha:
+		xor(string(e))
+	case Num:
+		xor(string(e))
+	}
+	return p
+}
+
+// shiftJIS returns the Shift JIS code of the 13-bit Kanji mode value w.
+func shiftJIS(w uint16) uint16 {
+	d := w/0xc0<<8 | w%0xc0
+	if d < 0x1f00 {
+		return d + 0x8140
+	}
+	return d + 0xc140
+}
+
+// EncodeSplit returns an encoding of text as a Structured Append sequence
+// of up to MaxSymbols codes, each no larger than version max.
+// When text fits a single code, it is encoded as a regular code.
+func EncodeSplit(text string, level Level, max Version) ([]*Code, error) {
+	opts := defaultOptions
+	opts.MaxVersion = max
+	if c, err := Encode(nil, text, level, &opts); err == nil {
+		return []*Code{c}, nil
+	}
+
+	var parts []string
+	capacity := max.DataBits(level)
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if len(parts) == MaxSymbols {
+			return nil, fmt.Errorf("text too long to encode as %d QR symbols", MaxSymbols)
+		}
+
+		// Find the longest prefix that fits a single symbol.
+		lo, hi := 0, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if partBits(string(runes[:mid]), max) <= capacity {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		if lo == 0 {
+			return nil, errors.New("version too small to split text")
+		}
+		parts = append(parts, string(runes[:lo]))
+		runes = runes[lo:]
+	}
+
+	var parity byte
+	for _, part := range parts {
+		parity ^= Parity(partEncoding(StructuredAppend{}, part, max))
+	}
+	codes := make([]*Code, len(parts))
+	for i, part := range parts {
+		sa := StructuredAppend{
+			Index:  i,
+			Total:  len(parts),
+			Parity: parity,
+		}
+		c, err := EncodeData(nil, partEncoding(sa, part, max), level, &opts)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = c
+	}
+	return codes, nil
+}
+
+// partEncoding returns the encoding of a part of a Structured Append sequence.
+func partEncoding(sa StructuredAppend, part string, v Version) Encoding {
+	enc := withUTF8(Segment(part, v))
+	segs, ok := enc.(Segments)
+	if !ok {
+		segs = Segments{enc}
+	}
+	return append(Segments{sa}, segs...)
+}
+
+func partBits(part string, v Version) int {
+	return partEncoding(StructuredAppend{Total: 1}, part, v).Bits(v)
+}
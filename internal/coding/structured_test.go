@@ -0,0 +1,75 @@
+package coding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStructuredAppend(t *testing.T) {
+	var b Bits
+	sa := StructuredAppend{Index: 2, Total: 4, Parity: 0xa5}
+	sa.Encode(&b, 1)
+	if got, want := bitString(&b), "0011"+"0010"+"0011"+"10100101"; got != want {
+		t.Errorf("have %s want %s", got, want)
+	}
+	if (StructuredAppend{Index: 0, Total: 17}).Check() {
+		t.Error("17 symbols must not be valid")
+	}
+}
+
+func TestEncodeSplit(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 10)
+	codes, err := EncodeSplit(text, M, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 6 {
+		t.Errorf("have %d codes want 6", len(codes))
+	}
+	for _, c := range codes {
+		if c.Version > 5 {
+			t.Errorf("have version %d want at most 5", c.Version)
+		}
+	}
+
+	codes, err = EncodeSplit("short", M, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 1 || codes[0].Version != 1 {
+		t.Errorf("have %d codes want 1", len(codes))
+	}
+
+	_, err = EncodeSplit(text, H, 1)
+	if err == nil {
+		t.Fatal("must fail")
+	}
+
+	// Kanji text is split into Kanji segments, whose parity
+	// is that of the Shift JIS bytes.
+	codes, err = EncodeSplit(strings.Repeat("漢字点", 41), L, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) < 2 {
+		t.Errorf("have %d codes want several", len(codes))
+	}
+}
+
+func TestParity(t *testing.T) {
+	testCases := []struct {
+		enc  Encoding
+		want byte
+	}{
+		{String("ab"), 'a' ^ 'b'},
+		{Segments{UTF8, String("ü")}, 0xc3 ^ 0xbc},
+		{Kanji("点"), 0x93 ^ 0x5f},
+		{Kanji("茗"), 0xe4 ^ 0xaa},
+		{Segments{StructuredAppend{Total: 2, Parity: 0xff}, Num("12"), Kanji("点")}, '1' ^ '2' ^ 0x93 ^ 0x5f},
+	}
+	for _, tc := range testCases {
+		if have := Parity(tc.enc); have != tc.want {
+			t.Errorf("%v: have %#02x want %#02x", tc.enc, have, tc.want)
+		}
+	}
+}
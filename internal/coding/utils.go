@@ -44,18 +44,122 @@ const (
 	MaxVersion Version = 40
 )
 
+// Micro QR versions. A Micro QR code with version Mn
+// has 2n+9 pixels on a side.
+const (
+	M1 Version = MaxVersion + 1 + iota
+	M2
+	M3
+	M4
+)
+
+// Micro reports whether v is a Micro QR version.
+func (v Version) Micro() bool {
+	return M1 <= v && v <= M4
+}
+
 func (v Version) String() string {
+	if v.Micro() {
+		return "M" + strconv.Itoa(int(v-M1+1))
+	}
+	if v.Rect() {
+		rt := &rtab[v-R7x43]
+		return "R" + strconv.Itoa(rt.height) + "x" + strconv.Itoa(rt.width)
+	}
 	return strconv.Itoa(int(v))
 }
 
 // DataBytes returns the number of data bytes that can be
 // stored in a QR code with the given version and level.
+// For M1 and M3 the last data byte holds only 4 bits.
 func (v Version) DataBytes(l Level) int {
+	if v.Micro() {
+		mt := &mtab[v-M1]
+		return mt.bytes - mt.check[l]
+	}
+	if v.Rect() {
+		if lev := rectLevel(l); lev >= 0 {
+			return rtab[v-R7x43].data[lev]
+		}
+		return 0
+	}
 	vt := &vtab[v]
 	lev := &vt.level[l]
 	return vt.bytes - lev.nblock*lev.check
 }
 
+// DataBits returns the number of data bits that can be
+// stored in a QR code with the given version and level.
+// It is 0 if the version has no such level.
+func (v Version) DataBits(l Level) int {
+	if v.Micro() {
+		mt := &mtab[v-M1]
+		if mt.check[l] == 0 {
+			return 0
+		}
+		n := v.DataBytes(l) * 8
+		if mt.half {
+			n -= 4
+		}
+		return n
+	}
+	return v.DataBytes(l) * 8
+}
+
+// blocks returns the number of data and check bytes of version v,
+// the number of blocks and the number of check bytes per block at level l.
+func (v Version) blocks(l Level) (bytes, nblock, check int) {
+	if v.Micro() {
+		mt := &mtab[v-M1]
+		return mt.bytes, 1, mt.check[l]
+	}
+	if v.Rect() {
+		rt := &rtab[v-R7x43]
+		lev := rectLevel(l)
+		return rt.bytes, rt.nblock[lev], (rt.bytes - rt.data[lev]) / rt.nblock[lev]
+	}
+	vt := &vtab[v]
+	return vt.bytes, vt.level[l].nblock, vt.level[l].check
+}
+
+// Correctable returns the number of codeword errors each block of a code
+// with version v can correct at level l: half its check bytes, once the
+// misdecode protection codewords of ISO/IEC 18004, table 9, are set aside.
+// It is 0 for M1, whose check bytes only detect errors.
+func (v Version) Correctable(l Level) int {
+	_, _, check := v.blocks(l)
+	return (check - v.misdecode(l)) / 2
+}
+
+// misdecode returns the number of check bytes of a code with version v
+// at level l that protect against misdecoding instead of correcting errors.
+func (v Version) misdecode(l Level) int {
+	switch {
+	case v == M1, v == M2 && l == M, v == M3 && l == L, v == M4 && l == L, v == 2 && l == L:
+		return 2
+	case v == M2 && l == L:
+		return 3
+	case v == 1:
+		return [4]int{3, 2, 1, 1}[l]
+	}
+	return 0
+}
+
+// Dims returns the number of pixels in a row
+// and the number of rows of a code with version v.
+func (v Version) Dims() (w, h int) {
+	switch {
+	case v.Micro():
+		s := 11 + 2*int(v-M1)
+		return s, s
+	case v.Rect():
+		rt := &rtab[v-R7x43]
+		return rt.width, rt.height
+	}
+	s := 17 + 4*int(v)
+	return s, s
+}
+
 func (v Version) sizeClass() int {
 	switch {
 	case v <= 9:
@@ -97,14 +201,19 @@ func (b *Bits) Append(p []byte) {
 }
 
 func (b *Bits) Pad(n int) {
+	b.pad(n, 4)
+}
+
+// pad is Pad with a terminator of term bits.
+func (b *Bits) pad(n, term int) {
 	if n < 0 {
 		panic("qr: invalid pad size")
 	}
-	if n <= 4 {
+	if n <= term {
 		b.Write(0, n)
 	} else {
-		b.Write(0, 4)
-		n -= 4
+		b.Write(0, term)
+		n -= term
 		n -= -b.Bits() & 7
 		b.Write(0, -b.Bits()&7)
 		pad := n / 8
@@ -122,23 +231,30 @@ func (b *Bits) Pad(n int) {
 var qrField = gf256.NewField(0x11d, 2)
 
 func (b *Bits) AddCheckBytes(v Version, l Level) {
+	if v.Micro() {
+		b.addMicroCheckBytes(v, l)
+		return
+	}
 	nd := v.DataBytes(l)
 	if b.nbit < nd*8 {
-		b.Pad(nd*8 - b.nbit)
+		term := 4
+		if v.Rect() {
+			term = 3
+		}
+		b.pad(nd*8-b.nbit, term)
 	}
 	if b.nbit != nd*8 {
 		panic("qr: too much data")
 	}
 
 	dat := b.Bytes()
-	vt := &vtab[v]
-	lev := &vt.level[l]
-	db := nd / lev.nblock
-	extra := nd % lev.nblock
-	chk := make([]byte, lev.check)
-	rs := gf256.NewRSEncoder(qrField, lev.check)
-	for i := 0; i < lev.nblock; i++ {
-		if i == lev.nblock-extra {
+	bytes, nblock, check := v.blocks(l)
+	db := nd / nblock
+	extra := nd % nblock
+	chk := make([]byte, check)
+	rs := gf256.NewRSEncoder(qrField, check)
+	for i := 0; i < nblock; i++ {
+		if i == nblock-extra {
 			db++
 		}
 		rs.ECC(dat[:db], chk)
@@ -146,7 +262,7 @@ func (b *Bits) AddCheckBytes(v Version, l Level) {
 		dat = dat[db:]
 	}
 
-	if len(b.Bytes()) != vt.bytes {
+	if len(b.Bytes()) != bytes {
 		panic("qr: internal error")
 	}
 }
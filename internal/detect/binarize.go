@@ -0,0 +1,218 @@
+// Package detect implements locating and sampling QR codes in images.
+package detect
+
+import "image"
+
+// A Bitmap is a binarized image.
+type Bitmap struct {
+	W, H     int
+	Pix      []bool // true is dark, row by row
+	Inverted bool   // dark and light are swapped, for light codes on a dark background
+}
+
+// Invert returns a copy of b with dark and light swapped.
+func (b *Bitmap) Invert() *Bitmap {
+	inv := &Bitmap{W: b.W, H: b.H, Pix: make([]bool, len(b.Pix)), Inverted: !b.Inverted}
+	for i, dark := range b.Pix {
+		inv.Pix[i] = !dark
+	}
+	return inv
+}
+
+// Black reports whether the pixel at (x, y) is dark.
+// Pixels outside of the bitmap are light.
+func (b *Bitmap) Black(x, y int) bool {
+	return 0 <= x && x < b.W && 0 <= y && y < b.H && b.Pix[y*b.W+x]
+}
+
+// Luminance returns the gray levels of img, row by row.
+// Transparent pixels are seen over a white background.
+func Luminance(img image.Image) (lum []uint8, w, h int) {
+	r := img.Bounds()
+	w, h = r.Dx(), r.Dy()
+	lum = make([]uint8, w*h)
+	switch m := img.(type) {
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			copy(lum[y*w:], m.Pix[m.PixOffset(r.Min.X, r.Min.Y+y):][:w])
+		}
+	case *image.YCbCr:
+		for y := 0; y < h; y++ {
+			copy(lum[y*w:], m.Y[m.YOffset(r.Min.X, r.Min.Y+y):][:w])
+		}
+	case *image.RGBA:
+		for y := 0; y < h; y++ {
+			row := m.Pix[m.PixOffset(r.Min.X, r.Min.Y+y):]
+			for x := 0; x < w; x++ {
+				p := row[4*x : 4*x+4]
+				lum[y*w+x] = gray(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101, uint32(p[3])*0x101)
+			}
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				lum[y*w+x] = gray(img.At(r.Min.X+x, r.Min.Y+y).RGBA())
+			}
+		}
+	}
+	return lum, w, h
+}
+
+// gray returns the gray level, like color.GrayModel, of the alpha-premultiplied
+// color r, g, b, a over a white background.
+func gray(r, g, b, a uint32) uint8 {
+	r += 0xffff - a
+	g += 0xffff - a
+	b += 0xffff - a
+	return uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+}
+
+// Binarization parameters of the local threshold: the size of the
+// blocks the image is split into, and the smallest difference between
+// the darkest and the lightest pixels of a block that has some detail.
+const (
+	blockSize       = 8
+	minDynamicRange = 24
+)
+
+// Binarize returns the bitmap of img, with a threshold computed
+// for each block of the image from the blocks around it,
+// so that uneven lighting does not hide parts of a code.
+// Images too small for that use a single threshold.
+func Binarize(img image.Image) *Bitmap {
+	return BinarizeLuminance(Luminance(img))
+}
+
+// BinarizeLuminance is like Binarize for the gray levels
+// returned by Luminance.
+func BinarizeLuminance(lum []uint8, w, h int) *Bitmap {
+	if w < 5*blockSize || h < 5*blockSize {
+		return ThresholdLuminance(lum, w, h)
+	}
+	b := &Bitmap{W: w, H: h, Pix: make([]bool, w*h)}
+
+	bw := (w + blockSize - 1) / blockSize
+	bh := (h + blockSize - 1) / blockSize
+	black := blackPoints(lum, w, h, bw, bh)
+	for by := 0; by < bh; by++ {
+		y0 := min(by*blockSize, h-blockSize)
+		top := clamp(by, 2, bh-3)
+		for bx := 0; bx < bw; bx++ {
+			x0 := min(bx*blockSize, w-blockSize)
+			left := clamp(bx, 2, bw-3)
+			sum := 0
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					sum += int(black[(top+dy)*bw+left+dx])
+				}
+			}
+			t := uint8(sum / 25)
+			for y := y0; y < y0+blockSize; y++ {
+				for x := x0; x < x0+blockSize; x++ {
+					b.Pix[y*w+x] = lum[y*w+x] <= t
+				}
+			}
+		}
+	}
+	return b
+}
+
+// ThresholdLuminance returns the bitmap of the gray levels lum
+// with a single threshold, the one that best separates them into
+// dark and light. It suits low contrast images with even lighting,
+// where the local thresholds of BinarizeLuminance can fail.
+func ThresholdLuminance(lum []uint8, w, h int) *Bitmap {
+	b := &Bitmap{W: w, H: h, Pix: make([]bool, w*h)}
+	t := otsu(lum)
+	for i, l := range lum {
+		b.Pix[i] = l <= t
+	}
+	return b
+}
+
+// blackPoints returns the average gray level of each block.
+// Blocks of a single color take a level below their darkest pixel,
+// unless their neighbors suggest they are part of a dark area.
+func blackPoints(lum []uint8, w, h, bw, bh int) []uint8 {
+	black := make([]uint8, bw*bh)
+	for by := 0; by < bh; by++ {
+		y0 := min(by*blockSize, h-blockSize)
+		for bx := 0; bx < bw; bx++ {
+			x0 := min(bx*blockSize, w-blockSize)
+			sum, lo, hi := 0, 255, 0
+			for y := y0; y < y0+blockSize; y++ {
+				for _, l := range lum[y*w+x0 : y*w+x0+blockSize] {
+					sum += int(l)
+					lo = min(lo, int(l))
+					hi = max(hi, int(l))
+				}
+			}
+			avg := sum / (blockSize * blockSize)
+			if hi-lo <= minDynamicRange {
+				avg = lo / 2
+				if by > 0 && bx > 0 {
+					near := (int(black[(by-1)*bw+bx]) + 2*int(black[by*bw+bx-1]) + int(black[(by-1)*bw+bx-1])) / 4
+					if lo < near {
+						avg = near
+					}
+				}
+			}
+			black[by*bw+bx] = uint8(avg)
+		}
+	}
+	return black
+}
+
+// otsu returns the threshold that best separates the gray levels in lum
+// into two classes.
+func otsu(lum []uint8) uint8 {
+	var hist [256]int
+	for _, l := range lum {
+		hist[l]++
+	}
+	total, sum := len(lum), 0
+	for i, n := range hist {
+		sum += i * n
+	}
+	var best float64
+	var t uint8
+	n0, sum0 := 0, 0
+	for i, n := range hist {
+		n0 += n
+		sum0 += i * n
+		n1 := total - n0
+		if n0 == 0 || n1 == 0 {
+			continue
+		}
+		m0 := float64(sum0) / float64(n0)
+		m1 := float64(sum-sum0) / float64(n1)
+		if v := float64(n0) * float64(n1) * (m0 - m1) * (m0 - m1); v > best {
+			best, t = v, uint8(i)
+		}
+	}
+	return t
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,274 @@
+package detect
+
+import (
+	"math"
+	"sort"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A Symbol is a candidate QR code: three finders, ordered
+// as the top left, top right and bottom left position boxes.
+type Symbol struct {
+	TopLeft, TopRight, BottomLeft Finder
+}
+
+// maxSymbolFinders bounds the number of finders tried together,
+// the most seen ones.
+const maxSymbolFinders = 64
+
+// Symbols returns the triples of finders that may be the position
+// boxes of a QR code, the most likely first. A finder may be part
+// of several triples.
+func Symbols(finders []Finder) []Symbol {
+	fs := finders
+	if len(fs) > maxSymbolFinders {
+		fs = fs[:maxSymbolFinders]
+	}
+	type scored struct {
+		s     Symbol
+		score float64
+	}
+	var all []scored
+	for i := 0; i < len(fs); i++ {
+		for j := i + 1; j < len(fs); j++ {
+			for k := j + 1; k < len(fs); k++ {
+				if s, score, ok := triple(fs[i], fs[j], fs[k]); ok {
+					all = append(all, scored{s, score})
+				}
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].score < all[j].score
+	})
+	syms := make([]Symbol, len(all))
+	for i := range all {
+		syms[i] = all[i].s
+	}
+	return syms
+}
+
+// triple orders a, b and c as the position boxes of a QR code
+// and reports whether they may be ones. The score is lower
+// the closer they are to a right isosceles triangle of finders
+// of the same size.
+func triple(a, b, c Finder) (Symbol, float64, bool) {
+	lo := math.Min(a.ModuleSize, math.Min(b.ModuleSize, c.ModuleSize))
+	hi := math.Max(a.ModuleSize, math.Max(b.ModuleSize, c.ModuleSize))
+	if hi > 2*lo {
+		return Symbol{}, 0, false
+	}
+
+	// The top left box is opposite to the longest side.
+	ab, bc, ac := dist(a.Point, b.Point), dist(b.Point, c.Point), dist(a.Point, c.Point)
+	switch {
+	case bc >= ab && bc >= ac:
+	case ac >= ab && ac >= bc:
+		a, b = b, a
+		bc, ac = ac, bc
+	default:
+		a, c = c, a
+		bc, ab = ab, bc
+	}
+	// a is the top left box, the legs are ab and ac.
+	legs := math.Max(ab, ac) / math.Min(ab, ac)
+	hyp := bc / math.Hypot(ab, ac)
+	module := (a.ModuleSize + b.ModuleSize + c.ModuleSize) / 3
+	if legs > 1.5 || hyp < 0.85 || hyp > 1.15 || (ab+ac)/2 < 10*module {
+		return Symbol{}, 0, false
+	}
+
+	// In image coordinates, the top right box is clockwise
+	// from the bottom left one around the top left one.
+	if (b.X-a.X)*(c.Y-a.Y)-(b.Y-a.Y)*(c.X-a.X) < 0 {
+		b, c = c, b
+	}
+	score := (legs - 1) + math.Abs(hyp-1) + (hi-lo)/lo
+	return Symbol{TopLeft: a, TopRight: b, BottomLeft: c}, score, true
+}
+
+// ModuleSize returns the size of a module of s, in pixels, measured
+// along the lines between the centers of the finders.
+func (b *Bitmap) ModuleSize(s Symbol) float64 {
+	tl, tr, bl := s.TopLeft.Point, s.TopRight.Point, s.BottomLeft.Point
+	sum := b.finderWidth(tl, tr) + b.finderWidth(tr, tl) + b.finderWidth(tl, bl) + b.finderWidth(bl, tl)
+	return sum / 16
+}
+
+// finderWidth returns the width of the ring of the finder centered at from,
+// 4 modules, measured along the line toward to.
+func (b *Bitmap) finderWidth(from, to Point) float64 {
+	back := Point{2*from.X - to.X, 2*from.Y - to.Y}
+	return b.finderRing(from, to) + b.finderRing(from, back)
+}
+
+// finderRing returns the distance from the inner edge to the outer edge
+// of the light and dark rings of a finder at from, 2 modules, along the line
+// toward to. Both edges go from dark to light, so that a threshold
+// that makes dark runs longer does not change the distance.
+func (b *Bitmap) finderRing(from, to Point) float64 {
+	d := dist(from, to)
+	if d == 0 {
+		return 0
+	}
+	dx, dy := (to.X-from.X)/d, (to.Y-from.Y)/d
+	state := 0 // 0 and 2 are dark runs, 1 is the light one
+	inner := 0.0
+	for t := 0.0; t < d; t += 0.25 {
+		x, y := from.X+t*dx, from.Y+t*dy
+		if x < 0 || y < 0 || x >= float64(b.W) || y >= float64(b.H) {
+			break
+		}
+		if b.Black(int(x), int(y)) != (state != 1) {
+			switch state {
+			case 0:
+				inner = t
+			case 2:
+				return t - inner
+			}
+			state++
+		}
+	}
+	return 0
+}
+
+// Dimensions returns the numbers of modules on a side s may have,
+// the most likely first.
+func (b *Bitmap) Dimensions(s Symbol) []int {
+	module := b.ModuleSize(s)
+	if module <= 0 {
+		return nil
+	}
+	h := math.Round(dist(s.TopLeft.Point, s.TopRight.Point) / module)
+	v := math.Round(dist(s.TopLeft.Point, s.BottomLeft.Point) / module)
+	dim := int((h+v)/2) + 7
+	switch dim & 3 {
+	case 0:
+		dim++
+	case 2:
+		dim--
+	case 3:
+		dim -= 2
+	}
+	dim = clamp(dim, 21, 177)
+	var dims []int
+	for _, d := range []int{dim, dim + 4, dim - 4} {
+		if 21 <= d && d <= 177 {
+			dims = append(dims, d)
+		}
+	}
+	return dims
+}
+
+// Grid samples the modules of s, a QR code with dim modules on a side,
+// and returns them with the transform from module to image coordinates.
+func (b *Bitmap) Grid(s Symbol, dim int) (*coding.Code, *Transform) {
+	t := b.transform(s, dim)
+	return b.Sample(t, dim, dim), t
+}
+
+// Sample samples the modules of a code with w modules in a row and h rows,
+// at the centers that t maps to b.
+func (b *Bitmap) Sample(t *Transform, w, h int) *coding.Code {
+	stride := (w + 7) &^ 7
+	c := &coding.Code{
+		Bitmap: make([]byte, stride*h),
+		Size:   w,
+		Width:  w,
+		Height: h,
+		Stride: stride,
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := t.Apply(Point{float64(x) + 0.5, float64(y) + 0.5})
+			if b.Black(int(math.Floor(p.X)), int(math.Floor(p.Y))) {
+				c.Bitmap[y*stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return c
+}
+
+// transform returns the transform from the module coordinates of s,
+// a QR code with dim modules on a side, to image coordinates.
+// It is a perspective transform anchored on the bottom right alignment box
+// when there is one, an affine one otherwise.
+func (b *Bitmap) transform(s Symbol, dim int) *Transform {
+	tl, tr, bl := s.TopLeft.Point, s.TopRight.Point, s.BottomLeft.Point
+	d := float64(dim)
+	from := [4]Point{{3.5, 3.5}, {d - 3.5, 3.5}, {d - 3.5, d - 3.5}, {3.5, d - 3.5}}
+	to := [4]Point{tl, tr, {tr.X - tl.X + bl.X, tr.Y - tl.Y + bl.Y}, bl}
+	if dim > 21 {
+		if p, ok := b.alignment(s, dim); ok {
+			from[2] = Point{d - 6.5, d - 6.5}
+			to[2] = p
+		}
+	}
+	t := QuadToQuad(from, to)
+	return &t
+}
+
+// alignment returns the center of the bottom right alignment box of s,
+// a QR code with dim modules on a side. It searches around the position
+// the finders predict, in wider and wider areas.
+func (b *Bitmap) alignment(s Symbol, dim int) (Point, bool) {
+	tl, tr, bl := s.TopLeft.Point, s.TopRight.Point, s.BottomLeft.Point
+	n := float64(dim - 7) // modules between the centers of the finders
+	u := Point{(tr.X - tl.X) / n, (tr.Y - tl.Y) / n}
+	v := Point{(bl.X - tl.X) / n, (bl.Y - tl.Y) / n}
+	est := Point{tl.X + (n-3)*(u.X+v.X), tl.Y + (n-3)*(u.Y+v.Y)}
+	module := (math.Hypot(u.X, u.Y) + math.Hypot(v.X, v.Y)) / 2
+
+	for _, allowance := range []float64{4, 8, 16} {
+		if p, ok := b.findBox(est, u, v, int(allowance*module)); ok {
+			return p, true
+		}
+	}
+	return Point{}, false
+}
+
+// findBox returns the center of a box like an alignment box, with module
+// vectors u and v, within r pixels of est along both axes.
+func (b *Bitmap) findBox(est, u, v Point, r int) (Point, bool) {
+	best, sum, count := 0, Point{}, 0.0
+	for y := int(est.Y) - r; y <= int(est.Y)+r; y++ {
+		for x := int(est.X) - r; x <= int(est.X)+r; x++ {
+			c := Point{float64(x) + 0.5, float64(y) + 0.5}
+			score := b.alignmentScore(c, u, v)
+			switch {
+			case score > best:
+				best, sum, count = score, c, 1
+			case score == best:
+				sum.X += c.X
+				sum.Y += c.Y
+				count++
+			}
+		}
+	}
+	if best < minAlignmentScore {
+		return Point{}, false
+	}
+	return Point{sum.X / count, sum.Y / count}, true
+}
+
+// minAlignmentScore is the number of the 25 modules of an alignment box
+// that must have the expected color.
+const minAlignmentScore = 23
+
+// alignmentScore returns the number of modules of an alignment box
+// centered at c, with module vectors u and v, that have the expected color.
+func (b *Bitmap) alignmentScore(c, u, v Point) int {
+	score := 0
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			x := c.X + float64(dx)*u.X + float64(dy)*v.X
+			y := c.Y + float64(dx)*u.Y + float64(dy)*v.Y
+			want := dx == -2 || dx == 2 || dy == -2 || dy == 2 || dx == 0 && dy == 0
+			if b.Black(int(math.Floor(x)), int(math.Floor(y))) == want {
+				score++
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,219 @@
+package detect
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// codeImage returns an image of c with scale pixels per module
+// and a quiet zone of 4 modules.
+func codeImage(c *coding.Code, scale int) *image.Gray {
+	w, h := (c.Width+8)*scale, (c.Height+8)*scale
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !c.Black(x/scale-4, y/scale-4) {
+				img.Pix[y*img.Stride+x] = 0xff
+			}
+		}
+	}
+	return img
+}
+
+// turned returns codeImage(c, scale) turned by deg degrees around its
+// center, over a white background large enough for all of it.
+// It is antialiased by sampling four points per pixel.
+func turned(c *coding.Code, scale int, deg float64) *image.Gray {
+	src := codeImage(c, scale)
+	w, h := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	n := int(math.Hypot(w, h)) + 2*scale
+	out := image.NewGray(image.Rect(0, 0, n, n))
+	sin, cos := math.Sincos(deg * math.Pi / 180)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			sum := 0
+			for _, d := range [][2]float64{{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.75}, {0.75, 0.75}} {
+				px, py := float64(x)+d[0]-float64(n)/2, float64(y)+d[1]-float64(n)/2
+				sx, sy := int(math.Floor(px*cos+py*sin+w/2)), int(math.Floor(py*cos-px*sin+h/2))
+				if image.Pt(sx, sy).In(src.Rect) {
+					sum += int(src.Pix[sy*src.Stride+sx])
+				} else {
+					sum += 0xff
+				}
+			}
+			out.Pix[y*out.Stride+x] = uint8(sum / 4)
+		}
+	}
+	return out
+}
+
+func TestQuadToQuad(t *testing.T) {
+	from := [4]Point{{3.5, 3.5}, {21.5, 3.5}, {18.5, 18.5}, {3.5, 21.5}}
+	to := [4]Point{{40, 30}, {200, 55}, {170, 210}, {20, 180}}
+	tr := QuadToQuad(from, to)
+	for i := range from {
+		if p := tr.Apply(from[i]); dist(p, to[i]) > 1e-9 {
+			t.Errorf("corner %d: have %v want %v", i, p, to[i])
+		}
+	}
+}
+
+func TestBinarize(t *testing.T) {
+	// A dark square over a light background that dims from left to right.
+	img := image.NewGray(image.Rect(0, 0, 100, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			l := 250 - x
+			if 30 <= x && x < 70 && 20 <= y && y < 60 {
+				l = 40
+			}
+			img.Pix[y*img.Stride+x] = uint8(l)
+		}
+	}
+	b := Binarize(img)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			want := 30 <= x && x < 70 && 20 <= y && y < 60
+			if b.Black(x, y) != want {
+				t.Fatalf("pixel %d,%d: have %v want %v", x, y, b.Black(x, y), want)
+			}
+		}
+	}
+}
+
+func TestFinders(t *testing.T) {
+	c, err := coding.Encode(nil, "finders", coding.M, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Binarize(codeImage(c, 5))
+	syms := Symbols(b.Finders())
+	if len(syms) == 0 {
+		t.Fatal("have no symbol")
+	}
+	s := syms[0]
+	// The finder centers are 3.5 modules from the corners of the code.
+	center := func(x, y int) Point {
+		return Point{5 * (4 + 3.5 + float64(x)), 5 * (4 + 3.5 + float64(y))}
+	}
+	n := c.Size - 7
+	for _, tc := range []struct {
+		name       string
+		have, want Point
+	}{
+		{"top left", s.TopLeft.Point, center(0, 0)},
+		{"top right", s.TopRight.Point, center(n, 0)},
+		{"bottom left", s.BottomLeft.Point, center(0, n)},
+	} {
+		if dist(tc.have, tc.want) > 0.5 {
+			t.Errorf("%s: have %v want %v", tc.name, tc.have, tc.want)
+		}
+	}
+	if m := b.ModuleSize(s); math.Abs(m-5) > 0.25 {
+		t.Errorf("have module size %v want 5", m)
+	}
+	if dims := b.Dimensions(s); len(dims) == 0 || dims[0] != c.Size {
+		t.Errorf("have dimensions %v want %d first", dims, c.Size)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	for _, v := range []coding.Version{1, 2, 7, 20} {
+		c, err := coding.Encode(nil, "grid", coding.L, &coding.Options{MinVersion: v, MaxVersion: v, Mask: coding.AutoMask})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := Binarize(codeImage(c, 3))
+		syms := Symbols(b.Finders())
+		if len(syms) == 0 {
+			t.Fatalf("version %d: have no symbol", v)
+		}
+		g, _ := b.Grid(syms[0], c.Size)
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				if g.Black(x, y) != c.Black(x, y) {
+					t.Fatalf("version %d: module %d,%d differs", v, x, y)
+				}
+			}
+		}
+	}
+}
+
+// sampled reports whether the first of the candidates cs
+// with the size of c samples its modules.
+func sampled(b *Bitmap, cs []Candidate, c *coding.Code) bool {
+	for _, cand := range cs {
+		if cand.Width != c.Width || cand.Height != c.Height {
+			continue
+		}
+		g := b.Sample(cand.T, cand.Width, cand.Height)
+		for y := 0; y < c.Height; y++ {
+			for x := 0; x < c.Width; x++ {
+				if g.Black(x, y) != c.Black(x, y) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func TestMicro(t *testing.T) {
+	for v := coding.M1; v <= coding.M4; v++ {
+		c, err := coding.Encode(nil, "1", coding.L, &coding.Options{MinVersion: v, MaxVersion: v, Mask: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := Binarize(codeImage(c, 3))
+		fs := b.Finders()
+		if len(fs) != 1 {
+			t.Fatalf("%v: have %d finders", v, len(fs))
+		}
+		cs := b.Micro(fs[0])
+		if len(cs) == 0 || cs[0].Width != c.Width {
+			t.Errorf("%v: have candidates %v", v, cs)
+		}
+		if !sampled(b, cs, c) {
+			t.Errorf("%v: modules differ", v)
+		}
+		for deg := 5; deg < 360; deg += 5 {
+			b := Binarize(turned(c, 8, float64(deg)))
+			fs := b.Finders()
+			if len(fs) == 0 || !sampled(b, b.Micro(fs[0]), c) {
+				t.Errorf("%v turned by %d°: modules differ", v, deg)
+			}
+		}
+	}
+}
+
+func TestRect(t *testing.T) {
+	for _, v := range []coding.Version{coding.R7x43, coding.R11x77, coding.R17x139} {
+		c, err := coding.Encode(nil, "1", coding.M, &coding.Options{MinVersion: v, MaxVersion: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := Binarize(codeImage(c, 3))
+		fs := b.Finders()
+		if len(fs) == 0 {
+			t.Fatalf("%v: have no finder", v)
+		}
+		cs := b.Rect(fs[0])
+		if len(cs) == 0 || cs[0].Width != c.Width || cs[0].Height != c.Height {
+			t.Errorf("%v: have %d candidates", v, len(cs))
+		}
+		if !sampled(b, cs, c) {
+			t.Errorf("%v: modules differ", v)
+		}
+		for deg := 5; deg < 360; deg += 5 {
+			b := Binarize(turned(c, 4, float64(deg)))
+			fs := b.Finders()
+			if len(fs) == 0 || !sampled(b, b.Rect(fs[0]), c) {
+				t.Errorf("%v turned by %d°: modules differ", v, deg)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package detect
+
+import (
+	"math"
+	"sort"
+)
+
+// A Finder is a candidate position box (finder pattern) of a code:
+// a dark square ring around a dark square, with runs of dark and
+// light modules in the ratio 1:1:3:1:1 along any line through its center.
+type Finder struct {
+	Point
+	ModuleSize float64 // estimated size of a module, in pixels
+	Count      int     // number of scan lines it was found on
+}
+
+// finderFinder collects finder candidates while scanning a bitmap.
+type finderFinder struct {
+	b       *Bitmap
+	finders []Finder
+}
+
+// Finders returns the finder patterns found in b, the most seen first.
+func (b *Bitmap) Finders() []Finder {
+	f := &finderFinder{b: b}
+	for y := 0; y < b.H; y++ {
+		f.scanRow(y)
+	}
+	sort.SliceStable(f.finders, func(i, j int) bool {
+		return f.finders[i].Count > f.finders[j].Count
+	})
+	return f.finders
+}
+
+// scanRow looks for the 1:1:3:1:1 runs of a finder along row y.
+func (f *finderFinder) scanRow(y int) {
+	b := f.b
+	var counts [5]int
+	state := 0
+	for x := 0; x < b.W; x++ {
+		if b.Black(x, y) {
+			if state&1 == 1 { // light to dark
+				state++
+			}
+			counts[state]++
+			continue
+		}
+		switch {
+		case state == 0 && counts[0] == 0: // leading light pixels
+		case state&1 == 1:
+			counts[state]++
+		case state < 4:
+			state++
+			counts[state]++
+		default:
+			if crossRatio(counts) {
+				f.check(counts, x, y)
+			}
+			// Keep the last dark and light runs, the start of another candidate.
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+	}
+	if state == 4 && crossRatio(counts) {
+		f.check(counts, b.W, y)
+	}
+}
+
+// crossRatio reports whether the runs in counts are close enough
+// to the 1:1:3:1:1 ratio of a finder.
+func crossRatio(counts [5]int) bool {
+	total := 0
+	for _, n := range counts {
+		if n == 0 {
+			return false
+		}
+		total += n
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	for i, n := range counts {
+		want, limit := module, module/2
+		if i == 2 {
+			want, limit = 3*module, 3*module/2
+		}
+		if math.Abs(want-float64(n)) >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// center returns the center of the runs in counts that end at end.
+func center(counts [5]int, end int) float64 {
+	return float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+}
+
+// check confirms the candidate whose runs along row y are in counts
+// and end at x, crossing it vertically, then horizontally again.
+func (f *finderFinder) check(counts [5]int, x, y int) {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	cx := center(counts, x)
+	cy, ok := f.crossCheck(int(cx), y, 0, 1, counts[2], total)
+	if !ok {
+		return
+	}
+	cx, ok = f.crossCheck(int(cx), int(cy), 1, 0, counts[2], total)
+	if !ok {
+		return
+	}
+	if !f.crossCheckDiagonal(int(cx), int(cy), counts[2]) {
+		return
+	}
+	f.add(Point{cx, cy}, float64(total)/7)
+}
+
+// crossCheck counts the runs of a finder through (x, y) along
+// the direction (dx, dy), either vertical or horizontal,
+// and returns the coordinate of their center along that direction.
+// Runs must be close to the size of the ones found while scanning.
+func (f *finderFinder) crossCheck(x, y, dx, dy, maxCount, total int) (float64, bool) {
+	counts, end, ok := f.runs(x, y, dx, dy, maxCount)
+	if !ok {
+		return 0, false
+	}
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	if 5*abs(n-total) >= 2*total || !crossRatio(counts) {
+		return 0, false
+	}
+	return center(counts, end), true
+}
+
+// crossCheckDiagonal reports whether the runs through (x, y)
+// along the diagonal also have the ratio of a finder.
+func (f *finderFinder) crossCheckDiagonal(x, y, maxCount int) bool {
+	counts, _, ok := f.runs(x, y, 1, 1, 2*maxCount)
+	return ok && crossRatio(counts)
+}
+
+// runs returns the lengths of the five runs of alternating colors
+// centered on the dark pixel at (x, y) along the direction (dx, dy),
+// and the position along that direction where they end.
+// Outer runs longer than maxCount do not belong to a finder.
+func (f *finderFinder) runs(x, y, dx, dy, maxCount int) ([5]int, int, bool) {
+	b := f.b
+	var counts [5]int
+	pos := func(i int) int { // position along the direction
+		if dx != 0 {
+			return x + i*dx
+		}
+		return y + i*dy
+	}
+	black := func(i int) bool { return b.Black(x+i*dx, y+i*dy) }
+	in := func(i int) bool {
+		px, py := x+i*dx, y+i*dy
+		return 0 <= px && px < b.W && 0 <= py && py < b.H
+	}
+
+	i := 0
+	for ; in(i) && black(i); i-- {
+		counts[2]++
+	}
+	for ; in(i) && !black(i) && counts[1] <= maxCount; i-- {
+		counts[1]++
+	}
+	for ; in(i) && black(i) && counts[0] <= maxCount; i-- {
+		counts[0]++
+	}
+	if !in(i) && counts[0] == 0 || counts[1] > maxCount || counts[0] > maxCount {
+		return counts, 0, false
+	}
+
+	i = 1
+	for ; in(i) && black(i); i++ {
+		counts[2]++
+	}
+	for ; in(i) && !black(i) && counts[3] <= maxCount; i++ {
+		counts[3]++
+	}
+	for ; in(i) && black(i) && counts[4] <= maxCount; i++ {
+		counts[4]++
+	}
+	if counts[3] > maxCount || counts[4] > maxCount {
+		return counts, 0, false
+	}
+	return counts, pos(i), true
+}
+
+// add merges the finder at p with an already found one at about
+// the same position, or records it as a new one.
+func (f *finderFinder) add(p Point, module float64) {
+	for i := range f.finders {
+		g := &f.finders[i]
+		if math.Abs(p.X-g.X) <= module && math.Abs(p.Y-g.Y) <= module &&
+			math.Abs(module-g.ModuleSize) <= math.Max(1, g.ModuleSize) {
+			n := float64(g.Count)
+			g.X = (g.X*n + p.X) / (n + 1)
+			g.Y = (g.Y*n + p.Y) / (n + 1)
+			g.ModuleSize = (g.ModuleSize*n + module) / (n + 1)
+			g.Count++
+			return
+		}
+	}
+	f.finders = append(f.finders, Finder{Point: p, ModuleSize: module, Count: 1})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
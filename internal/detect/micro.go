@@ -0,0 +1,356 @@
+package detect
+
+import (
+	"math"
+	"sort"
+)
+
+// A Candidate is a possible Micro QR or rMQR code, located from its
+// single finder: its size, with Width modules in a row and Height rows,
+// and the transform from its module coordinates to image coordinates.
+// A Mirrored candidate is seen in a mirror, its transform includes it.
+type Candidate struct {
+	Width, Height int
+	T             *Transform
+	Mirrored      bool
+}
+
+// A frame places a code with its finder at the top left:
+// the module at (x, y) is centered at o + (x+0.5)u + (y+0.5)v.
+type frame struct {
+	o, u, v Point
+}
+
+// at returns the image position of the point (x, y) in module coordinates.
+func (fr *frame) at(x, y float64) Point {
+	return Point{fr.o.X + x*fr.u.X + y*fr.v.X, fr.o.Y + x*fr.u.Y + y*fr.v.Y}
+}
+
+// mirrored returns fr seen in a mirror, with the same finder and top row.
+func (fr *frame) mirrored() frame {
+	return frame{Point{fr.o.X + 7*fr.v.X, fr.o.Y + 7*fr.v.Y}, fr.u, Point{-fr.v.X, -fr.v.Y}}
+}
+
+// vec returns the image vector of the vector d in module coordinates.
+func (fr *frame) vec(d Point) Point {
+	return Point{d.X*fr.u.X + d.Y*fr.v.X, d.X*fr.u.Y + d.Y*fr.v.Y}
+}
+
+// transform returns the affine transform of fr.
+func (fr *frame) transform() *Transform {
+	return &Transform{fr.u.X, fr.v.X, fr.o.X, fr.u.Y, fr.v.Y, fr.o.Y, 0, 0, 1}
+}
+
+// module reports whether the module at (x, y) of fr is dark in b.
+func (b *Bitmap) module(fr *frame, x, y int) bool {
+	p := fr.at(float64(x)+0.5, float64(y)+0.5)
+	return b.Black(int(math.Floor(p.X)), int(math.Floor(p.Y)))
+}
+
+// frames returns the four ways a code may be turned around the finder f,
+// each with the finder at the top left.
+func (b *Bitmap) frames(f Finder) []frame {
+	e, module, ok := b.axis(f)
+	if !ok {
+		return nil
+	}
+	frames := make([]frame, 4)
+	u := Point{e.X * module, e.Y * module}
+	for i := range frames {
+		v := Point{-u.Y, u.X}
+		o := Point{f.X - 3.5*(u.X+v.X), f.Y - 3.5*(u.Y+v.Y)}
+		frames[i] = frame{o, u, v}
+		u = v
+	}
+	return frames
+}
+
+// finderRays is the number of rays cast from the center of a finder
+// to find the outer edge of its ring.
+const finderRays = 64
+
+// axis returns the direction of a side of the finder f, a unit vector,
+// and the size of its modules. The direction is the one that best fits
+// a square to the outer edge of the finder ring.
+func (b *Bitmap) axis(f Finder) (Point, float64, bool) {
+	var edge []Point
+	for i := 0; i < finderRays; i++ {
+		a := 2 * math.Pi * float64(i) / finderRays
+		to := Point{f.X + 6*f.ModuleSize*math.Cos(a), f.Y + 6*f.ModuleSize*math.Sin(a)}
+		if p, ok := b.outerEdge(f.Point, to); ok {
+			edge = append(edge, Point{p.X - f.X, p.Y - f.Y})
+		}
+	}
+	if len(edge) < finderRays/2 {
+		return Point{}, 0, false
+	}
+
+	// The points of the edge of a square with a side along angle a
+	// are all as far from its center along one of the axes.
+	spread := func(a float64) float64 {
+		c, s := math.Cos(a), math.Sin(a)
+		var sum, sum2 float64
+		for _, p := range edge {
+			d := math.Max(math.Abs(p.X*c+p.Y*s), math.Abs(p.Y*c-p.X*s))
+			sum += d
+			sum2 += d * d
+		}
+		n := float64(len(edge))
+		return sum2/n - (sum/n)*(sum/n)
+	}
+	best, lowest := 0.0, math.Inf(1)
+	for a := 0.0; a < 90; a++ {
+		if v := spread(a * math.Pi / 180); v < lowest {
+			best, lowest = a, v
+		}
+	}
+	for a := best - 1; a <= best+1; a += 0.1 {
+		if v := spread(a * math.Pi / 180); v < lowest {
+			best, lowest = a, v
+		}
+	}
+	e := Point{math.Cos(best * math.Pi / 180), math.Sin(best * math.Pi / 180)}
+
+	// The rings are 2 modules wide. Their edges are staircases
+	// when the finder is turned, so they are measured along
+	// lines on both sides of the center too.
+	sum, n := 0.0, 0
+	for _, d := range []Point{e, {-e.X, -e.Y}, {-e.Y, e.X}, {e.Y, -e.X}} {
+		for k := -2; k <= 2; k++ {
+			o := float64(k) * f.ModuleSize / 2
+			from := Point{f.X - o*d.Y, f.Y + o*d.X}
+			if r := b.finderRing(from, Point{from.X + 6*f.ModuleSize*d.X, from.Y + 6*f.ModuleSize*d.Y}); r > 0 {
+				sum += r
+				n++
+			}
+		}
+	}
+	module := f.ModuleSize
+	if n > 0 {
+		module = sum / float64(n) / 2
+	}
+	return b.alignAxis(f, e, module), module, true
+}
+
+// alignAxis returns the direction, within 8 degrees of e, along which
+// the finder f with modules of the given size best matches its pattern.
+// The staircase edges of a turned finder leave the fit of axis a few
+// degrees off. The directions with the best score are averaged.
+func (b *Bitmap) alignAxis(f Finder, e Point, module float64) Point {
+	a0 := math.Atan2(e.Y, e.X) * 180 / math.Pi
+	best, sum, count := -1, 0.0, 0.0
+	for d := -8.0; d <= 8; d += 0.25 {
+		a := (a0 + d) * math.Pi / 180
+		score := b.finderScore(f, Point{math.Cos(a) * module, math.Sin(a) * module})
+		switch {
+		case score > best:
+			best, sum, count = score, d, 1
+		case score == best:
+			sum += d
+			count++
+		}
+	}
+	a := (a0 + sum/count) * math.Pi / 180
+	return Point{math.Cos(a), math.Sin(a)}
+}
+
+// finderScore returns the number of points of the finder f, with the
+// module vector u along a side, that have the expected color: 4 by 4
+// points in each module of the finder and of the light ring around it.
+func (b *Bitmap) finderScore(f Finder, u Point) int {
+	score := 0
+	for my := -4; my <= 4; my++ {
+		for mx := -4; mx <= 4; mx++ {
+			ring := abs(mx)
+			if r := abs(my); r > ring {
+				ring = r
+			}
+			want := ring != 2 && ring != 4
+			for sy := 0; sy < 4; sy++ {
+				for sx := 0; sx < 4; sx++ {
+					x := float64(mx) + (float64(sx)-1.5)/4
+					y := float64(my) + (float64(sy)-1.5)/4
+					px := f.X + x*u.X - y*u.Y
+					py := f.Y + x*u.Y + y*u.X
+					if b.Black(int(math.Floor(px)), int(math.Floor(py))) == want {
+						score++
+					}
+				}
+			}
+		}
+	}
+	return score
+}
+
+// outerEdge returns the point where the line from the center of a finder
+// at from toward to leaves its dark outer ring.
+func (b *Bitmap) outerEdge(from, to Point) (Point, bool) {
+	d := dist(from, to)
+	dx, dy := (to.X-from.X)/d, (to.Y-from.Y)/d
+	state := 0 // 0 and 2 are dark runs, 1 is the light one
+	for t := 0.0; t < d; t += 0.25 {
+		x, y := from.X+t*dx, from.Y+t*dy
+		if b.Black(int(math.Floor(x)), int(math.Floor(y))) != (state != 1) {
+			if state == 2 {
+				return Point{x, y}, true
+			}
+			state++
+		}
+	}
+	return Point{}, false
+}
+
+// timing returns the size, up to max, that the timing pattern along
+// the top row of fr, or along its left column, gives to the code:
+// its modules alternate from the light one next to the finder
+// up to the edge of the code.
+func (b *Bitmap) timing(fr *frame, column bool, max int) int {
+	for i := 7; i < max; i++ {
+		x, y := i, 0
+		if column {
+			x, y = 0, i
+		}
+		if b.module(fr, x, y) != (i%2 == 0) {
+			return i - 1
+		}
+	}
+	return max
+}
+
+// A mark is a point of a code at m in module coordinates,
+// measured at p in the image.
+type mark struct {
+	m, p Point
+}
+
+// fit returns the frame of the similarity that best maps the marks,
+// at least two of them, onto their measured positions: a turn,
+// a scale and a shift, and a mirror when fr has one.
+func (fr *frame) fit(marks []mark) frame {
+	// With the points as complex numbers, the module (x, y) is at
+	// o + (x + iy)u, or o + (x - iy)u in a mirror: v is iu or -iu.
+	side := 1.0
+	if fr.u.X*fr.v.Y-fr.u.Y*fr.v.X < 0 {
+		side = -1
+	}
+	var mz, mp complex128
+	for _, k := range marks {
+		mz += complex(k.m.X, side*k.m.Y)
+		mp += complex(k.p.X, k.p.Y)
+	}
+	n := complex(float64(len(marks)), 0)
+	mz, mp = mz/n, mp/n
+	var num complex128
+	var den float64
+	for _, k := range marks {
+		z := complex(k.m.X, side*k.m.Y) - mz
+		p := complex(k.p.X, k.p.Y) - mp
+		num += p * complex(real(z), -imag(z))
+		den += real(z)*real(z) + imag(z)*imag(z)
+	}
+	u := num / complex(den, 0)
+	o := mp - u*mz
+	return frame{
+		o: Point{real(o), imag(o)},
+		u: Point{real(u), imag(u)},
+		v: Point{-side * imag(u), side * real(u)},
+	}
+}
+
+// A timingLine is a timing pattern along an edge of a code:
+// its n dark modules are centered at start + 2k*along,
+// in module coordinates, and out points out of the code.
+type timingLine struct {
+	start, along, out Point
+	n                 int
+}
+
+// refine measures the dark modules of lines, one of each line at a time
+// from their starts, where fr places them. After each round it fits fr
+// to the marks and to the modules measured so far, so that the next ones
+// are looked for in the right places. It returns the refined frame
+// and all the marks.
+func (b *Bitmap) refine(fr frame, marks []mark, lines []timingLine) (frame, []mark) {
+	for k := 0; ; k++ {
+		more := false
+		for _, l := range lines {
+			if k >= l.n {
+				continue
+			}
+			more = true
+			m := Point{l.start.X + 2*float64(k)*l.along.X, l.start.Y + 2*float64(k)*l.along.Y}
+			if p, ok := b.darkCenter(&fr, m, l.along, l.out); ok {
+				marks = append(marks, mark{m, p})
+			}
+		}
+		if !more {
+			return fr, marks
+		}
+		if len(marks) >= 2 {
+			fr = fr.fit(marks)
+		}
+	}
+}
+
+// darkCenter returns the center of the dark module centered at m in fr,
+// along a timing pattern: midway between its light neighbors along it,
+// and half a module in from its edge on the side out of the code.
+// It reports false unless the module is a single dark one there.
+func (b *Bitmap) darkCenter(fr *frame, m, along, out Point) (Point, bool) {
+	c := fr.at(m.X, m.Y)
+	a, o := fr.vec(along), fr.vec(out)
+	lo, hi := b.darkRun(c, Point{-a.X, -a.Y}), b.darkRun(c, a)
+	if lo < 0 || hi < 0 || lo+hi < 0.5 || lo+hi > 1.5 {
+		return Point{}, false
+	}
+	c = Point{c.X + (hi-lo)/2*a.X, c.Y + (hi-lo)/2*a.Y}
+	e := b.darkRun(c, o)
+	if e < 0 || e > 1 {
+		return Point{}, false
+	}
+	return Point{c.X + (e-0.5)*o.X, c.Y + (e-0.5)*o.Y}, true
+}
+
+// darkRun returns how far the dark run at from goes in the direction d,
+// in lengths of d, -1 when from is light or the run is longer than 1.5.
+func (b *Bitmap) darkRun(from, d Point) float64 {
+	for t := 0.0; t < 1.5; t += 1.0 / 32 {
+		if !b.Black(int(math.Floor(from.X+t*d.X)), int(math.Floor(from.Y+t*d.Y))) {
+			if t == 0 {
+				return -1
+			}
+			return t
+		}
+	}
+	return -1
+}
+
+// Micro returns the candidate Micro QR codes with their finder at f,
+// the most likely first. Their frames are refined along both timing
+// patterns, as far as the largest code.
+func (b *Bitmap) Micro(f Finder) []Candidate {
+	var cs []Candidate
+	frames := b.frames(f)
+	for i := range frames {
+		// The dark modules of both timing patterns, next to the finder.
+		fr, _ := b.refine(frames[i], []mark{{Point{3.5, 3.5}, f.Point}}, []timingLine{
+			{Point{8.5, 0.5}, Point{1, 0}, Point{0, -1}, 5},
+			{Point{0.5, 8.5}, Point{0, 1}, Point{-1, 0}, 5},
+		})
+		// Both timing patterns start next to the finder,
+		// the other sides of the finder face the quiet zone.
+		row, col := b.timing(&fr, false, 17), b.timing(&fr, true, 17)
+		if row < 9 || col < 9 {
+			continue
+		}
+		est := (row + col) / 2
+		sizes := []int{11, 13, 15, 17}
+		sort.SliceStable(sizes, func(i, j int) bool {
+			return abs(sizes[i]-est) < abs(sizes[j]-est)
+		})
+		for _, s := range sizes {
+			cs = append(cs, Candidate{Width: s, Height: s, T: fr.transform()})
+		}
+	}
+	return cs
+}
@@ -0,0 +1,86 @@
+package detect
+
+import (
+	"math"
+	"sort"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// Rect returns the candidate rMQR codes with their finder at f,
+// the most likely first, then the mirrored ones. The frames of the
+// finder are refined along the top timing pattern. Each version is
+// then tried where its bottom right sub-finder would be, and the frame
+// refined again from the sub-finder found there and the other timing
+// patterns of the version.
+func (b *Bitmap) Rect(f Finder) []Candidate {
+	type scored struct {
+		c     Candidate
+		score float64
+	}
+	var all []scored
+	frames := b.frames(f)
+	for _, mirrored := range []bool{false, true} {
+		for i := range frames {
+			fr := frames[i]
+			if mirrored {
+				fr = fr.mirrored()
+			}
+			fr, top := b.refine(fr, []mark{{Point{3.5, 3.5}, f.Point}}, []timingLine{
+				{Point{8.5, 0.5}, Point{1, 0}, Point{0, -1}, (139 - 8) / 2},
+			})
+			// The top timing pattern starts next to the finder.
+			if b.timing(&fr, false, 11) < 11 {
+				continue
+			}
+			module := math.Hypot(fr.u.X, fr.u.Y)
+			for v := coding.R7x43; v <= coding.R17x139; v++ {
+				w, h := v.Dims()
+				est := fr.at(float64(w)-2.5, float64(h)-2.5)
+				// The error on the module size and on the direction
+				// of the finder adds up along the code.
+				r := 2 + 0.03*math.Hypot(float64(w-6), float64(h-6))
+				p, ok := b.findBox(est, fr.u, fr.v, int(r*module))
+				if !ok {
+					continue
+				}
+				marks := append([]mark{{Point{float64(w) - 2.5, float64(h) - 2.5}, p}}, top...)
+				g, _ := b.refine(fr.fit(marks), marks, rectTiming(w, h))
+				all = append(all, scored{
+					Candidate{Width: w, Height: h, T: g.transform(), Mirrored: mirrored},
+					dist(p, est) / module,
+				})
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].c.Mirrored != all[j].c.Mirrored {
+			return !all[i].c.Mirrored
+		}
+		return all[i].score < all[j].score
+	})
+	cs := make([]Candidate, len(all))
+	for i := range all {
+		cs[i] = all[i].c
+	}
+	return cs
+}
+
+// rectTiming returns the timing patterns of an rMQR code of w by h
+// modules but the top one, between its finders and corner patterns.
+func rectTiming(w, h int) []timingLine {
+	bottom := 4 // after the bottom left corner pattern
+	if h == 7 {
+		bottom = 8 // after the finder
+	}
+	lines := []timingLine{
+		{Point{float64(bottom) + 0.5, float64(h) - 0.5}, Point{1, 0}, Point{0, 1}, (w-7-bottom)/2 + 1},
+	}
+	if h >= 11 {
+		lines = append(lines, timingLine{Point{float64(w) - 0.5, 4.5}, Point{0, 1}, Point{1, 0}, (h-11)/2 + 1})
+	}
+	if h >= 13 {
+		lines = append(lines, timingLine{Point{0.5, 8.5}, Point{0, 1}, Point{-1, 0}, (h-13)/2 + 1})
+	}
+	return lines
+}
@@ -0,0 +1,88 @@
+package detect
+
+import "math"
+
+// A Point is a position in an image or in a grid of modules.
+type Point struct {
+	X, Y float64
+}
+
+// dist returns the distance between p and q.
+func dist(p, q Point) float64 {
+	return math.Hypot(p.X-q.X, p.Y-q.Y)
+}
+
+// A Transform is a perspective transform between two planes,
+// the homography of projective geometry.
+type Transform [9]float64 // row-major 3x3 matrix
+
+// Apply returns the image of p by t.
+func (t *Transform) Apply(p Point) Point {
+	d := t[6]*p.X + t[7]*p.Y + t[8]
+	return Point{
+		(t[0]*p.X + t[1]*p.Y + t[2]) / d,
+		(t[3]*p.X + t[4]*p.Y + t[5]) / d,
+	}
+}
+
+// Transposed returns the transform that maps (x, y) where t maps (y, x),
+// for a grid of modules seen in a mirror.
+func (t *Transform) Transposed() *Transform {
+	return &Transform{t[1], t[0], t[2], t[4], t[3], t[5], t[7], t[6], t[8]}
+}
+
+// QuadToQuad returns the transform that maps the corners
+// of the quadrilateral from onto the ones of to, in order.
+func QuadToQuad(from, to [4]Point) Transform {
+	a := squareToQuad(to)
+	b := squareToQuad(from)
+	b = b.adjoint()
+	return a.times(&b)
+}
+
+// squareToQuad returns the transform that maps the unit square
+// (0, 0), (1, 0), (1, 1), (0, 1) onto q.
+func squareToQuad(q [4]Point) Transform {
+	dx3 := q[0].X - q[1].X + q[2].X - q[3].X
+	dy3 := q[0].Y - q[1].Y + q[2].Y - q[3].Y
+	if dx3 == 0 && dy3 == 0 { // affine
+		return Transform{
+			q[1].X - q[0].X, q[2].X - q[1].X, q[0].X,
+			q[1].Y - q[0].Y, q[2].Y - q[1].Y, q[0].Y,
+			0, 0, 1,
+		}
+	}
+	dx1, dx2 := q[1].X-q[2].X, q[3].X-q[2].X
+	dy1, dy2 := q[1].Y-q[2].Y, q[3].Y-q[2].Y
+	den := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / den
+	h := (dx1*dy3 - dx3*dy1) / den
+	return Transform{
+		q[1].X - q[0].X + g*q[1].X, q[3].X - q[0].X + h*q[3].X, q[0].X,
+		q[1].Y - q[0].Y + g*q[1].Y, q[3].Y - q[0].Y + h*q[3].Y, q[0].Y,
+		g, h, 1,
+	}
+}
+
+// adjoint returns the adjoint of t, which is its inverse up to a scale
+// factor that does not matter to a perspective transform.
+func (t *Transform) adjoint() Transform {
+	return Transform{
+		t[4]*t[8] - t[5]*t[7], t[2]*t[7] - t[1]*t[8], t[1]*t[5] - t[2]*t[4],
+		t[5]*t[6] - t[3]*t[8], t[0]*t[8] - t[2]*t[6], t[2]*t[3] - t[0]*t[5],
+		t[3]*t[7] - t[4]*t[6], t[1]*t[6] - t[0]*t[7], t[0]*t[4] - t[1]*t[3],
+	}
+}
+
+// times returns the transform that applies u, then t.
+func (t *Transform) times(u *Transform) Transform {
+	var r Transform
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i*3+j] += t[i*3+k] * u[k*3+j]
+			}
+		}
+	}
+	return r
+}
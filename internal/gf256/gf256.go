@@ -1,6 +1,9 @@
 package gf256
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+)
 
 // An RSEncoder implements Reed-Solomon encoding
 // over a given field using a given number of error correction bytes.
@@ -75,6 +78,215 @@ func (rs *RSEncoder) ECC(data, check []byte) {
 	rs.p = p
 }
 
+// ErrTooManyErrors is returned by RSDecoder.Decode
+// when a block has more errors than it can correct.
+var ErrTooManyErrors = errors.New("gf256: too many errors to correct")
+
+// An RSDecoder implements Reed-Solomon decoding
+// over a given field using a given number of error correction bytes.
+// It reverses the encoding of an RSEncoder with the same parameters.
+type RSDecoder struct {
+	f *Field
+	c int
+}
+
+// NewRSDecoder returns a new Reed-Solomon decoder
+// over the given field and number of error correction bytes.
+func NewRSDecoder(f *Field, c int) *RSDecoder {
+	return &RSDecoder{f: f, c: c}
+}
+
+// Decode corrects in place block, data bytes followed by the check bytes.
+// Erasures lists the indexes into block of bytes known to be wrong.
+// A block with v errors at unknown positions and e erasures
+// can be corrected if 2v + e is at most the number of check bytes.
+//
+// Decode returns the number of bytes it corrected.
+// If the block cannot be corrected, it returns ErrTooManyErrors
+// and leaves block unchanged. Decode panics if an erasure is out of block.
+func (rs *RSDecoder) Decode(block []byte, erasures []int) (int, error) {
+	n := len(block)
+	if n < rs.c || n > 255 {
+		panic("gf256: invalid block length")
+	}
+	if len(erasures) > rs.c {
+		return 0, ErrTooManyErrors
+	}
+	f := rs.f
+
+	// Polynomials below are stored with the least significant term first.
+	// Byte i of block is the coefficient of x^(n-1-i), its locator is
+	// alpha^(n-1-i). The roots of the generator are alpha^0 to alpha^(c-1).
+	synd := rs.syndromes(block)
+	if isZero(synd) {
+		return 0, nil
+	}
+
+	// The erasure locator is the product of (1 - X x)
+	// for the locators X of the erasures.
+	gamma := []byte{1}
+	for _, i := range erasures {
+		if i < 0 || i >= n {
+			panic("gf256: invalid erasure position")
+		}
+		gamma = f.polyMul(gamma, []byte{1, f.Exp(n - 1 - i)})
+	}
+
+	// Berlekamp-Massey, starting from the erasure locator,
+	// finds the locator lambda of both errors and erasures.
+	e := len(erasures)
+	lambda := append([]byte(nil), gamma...)
+	prev := append([]byte(nil), gamma...)
+	l := e
+	for r := e + 1; r <= rs.c; r++ {
+		var delta byte
+		for j := 0; j < len(lambda) && j < r; j++ {
+			delta ^= f.Mul(lambda[j], synd[r-1-j])
+		}
+		prev = append([]byte{0}, prev...) // prev *= x
+		if delta == 0 {
+			continue
+		}
+		next := f.polyAdd(lambda, f.polyScale(prev, delta))
+		if 2*l <= r+e-1 {
+			prev = f.polyScale(lambda, f.Inv(delta))
+			l = r + e - l
+		}
+		lambda = next
+	}
+	lambda = trim(lambda)
+	deg := len(lambda) - 1
+	if deg != l || 2*(l-e)+e > rs.c {
+		return 0, ErrTooManyErrors
+	}
+
+	// Chien search: the errors are at the positions
+	// whose locators X have lambda(1/X) = 0.
+	var pos []int
+	for i := 0; i < n; i++ {
+		if f.polyEval(lambda, f.Inv(f.Exp(n-1-i))) == 0 {
+			pos = append(pos, i)
+		}
+	}
+	if len(pos) != deg {
+		return 0, ErrTooManyErrors
+	}
+
+	// Forney: the error value at locator X is X omega(1/X) / lambda'(1/X),
+	// where omega = synd lambda mod x^c.
+	omega := f.polyMul(synd, lambda)
+	if len(omega) > rs.c {
+		omega = omega[:rs.c]
+	}
+	deriv := make([]byte, len(lambda)-1)
+	for i := 1; i < len(lambda); i += 2 {
+		deriv[i-1] = lambda[i]
+	}
+	fix := make([]byte, len(pos))
+	for k, i := range pos {
+		x := f.Exp(n - 1 - i)
+		xinv := f.Inv(x)
+		d := f.polyEval(deriv, xinv)
+		if d == 0 {
+			return 0, ErrTooManyErrors
+		}
+		fix[k] = f.Mul(f.Mul(x, f.polyEval(omega, xinv)), f.Inv(d))
+	}
+
+	for k, i := range pos {
+		block[i] ^= fix[k]
+	}
+	if !isZero(rs.syndromes(block)) {
+		for k, i := range pos {
+			block[i] ^= fix[k]
+		}
+		return 0, ErrTooManyErrors
+	}
+	corrected := 0
+	for _, v := range fix {
+		if v != 0 {
+			corrected++
+		}
+	}
+	return corrected, nil
+}
+
+// syndromes returns the values of block at the roots of the generator.
+func (rs *RSDecoder) syndromes(block []byte) []byte {
+	f := rs.f
+	synd := make([]byte, rs.c)
+	for j := range synd {
+		x := f.Exp(j)
+		var s byte
+		for _, b := range block {
+			s = f.Mul(s, x) ^ b
+		}
+		synd[j] = s
+	}
+	return synd
+}
+
+func isZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trim drops the zero most significant terms of p,
+// stored with the least significant term first.
+func trim(p []byte) []byte {
+	for len(p) > 1 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// polyEval returns the value of p at x,
+// with the least significant term of p first.
+func (f *Field) polyEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = f.Mul(y, x) ^ p[i]
+	}
+	return y
+}
+
+// polyMul returns the product of p and q,
+// with the least significant terms first.
+func (f *Field) polyMul(p, q []byte) []byte {
+	z := make([]byte, len(p)+len(q)-1)
+	for i, a := range p {
+		for j, b := range q {
+			z[i+j] ^= f.Mul(a, b)
+		}
+	}
+	return z
+}
+
+// polyAdd returns the sum of p and q.
+func (f *Field) polyAdd(p, q []byte) []byte {
+	if len(p) < len(q) {
+		p, q = q, p
+	}
+	z := append([]byte(nil), p...)
+	for i, b := range q {
+		z[i] ^= b
+	}
+	return z
+}
+
+// polyScale returns p multiplied by c.
+func (f *Field) polyScale(p []byte, c byte) []byte {
+	z := make([]byte, len(p))
+	for i, a := range p {
+		z[i] = f.Mul(a, c)
+	}
+	return z
+}
+
 // Field represents an instance of GF(256) defined by a specific polynomial.
 type Field struct {
 	log [256]byte // log[0] is unused
@@ -3,6 +3,7 @@ package gf256
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -189,3 +190,87 @@ func generates(alpha, poly int) bool {
 	}
 	return true
 }
+
+func TestDecode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	testCases := []struct {
+		data, check int
+		errors      int
+		erasures    int
+	}{
+		{16, 10, 0, 0},
+		{16, 10, 1, 0},
+		{16, 10, 5, 0},
+		{16, 10, 0, 10},
+		{16, 10, 3, 4},
+		{16, 10, 4, 2},
+		{2, 7, 3, 1},
+		{223, 32, 16, 0},
+		{223, 32, 10, 12},
+	}
+
+	for _, tc := range testCases {
+		data := make([]byte, tc.data)
+		rnd.Read(data)
+		block := append(data, make([]byte, tc.check)...)
+		NewRSEncoder(f, tc.check).ECC(data, block[tc.data:])
+		want := append([]byte(nil), block...)
+
+		perm := rnd.Perm(len(block))
+		var erasures []int
+		for i, pos := range perm[:tc.errors+tc.erasures] {
+			block[pos] ^= byte(1 + rnd.Intn(255))
+			if i >= tc.errors {
+				erasures = append(erasures, pos)
+			}
+		}
+
+		n, err := NewRSDecoder(f, tc.check).Decode(block, erasures)
+		if err != nil {
+			t.Errorf("%+v: %v", tc, err)
+			continue
+		}
+		if n != tc.errors+tc.erasures {
+			t.Errorf("%+v: corrected %d bytes", tc, n)
+		}
+		if !bytes.Equal(block, want) {
+			t.Errorf("%+v: have %x want %x", tc, block, want)
+		}
+	}
+}
+
+func TestDecodeTooManyErrors(t *testing.T) {
+	data := []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	block := append(data, 0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55)
+	for i := 0; i < 6; i++ {
+		block[i*3] ^= 0xff
+	}
+	damaged := append([]byte(nil), block...)
+
+	rs := NewRSDecoder(f, 10)
+	if _, err := rs.Decode(block, nil); err != ErrTooManyErrors {
+		t.Errorf("have %v want %v", err, ErrTooManyErrors)
+	}
+	if !bytes.Equal(block, damaged) {
+		t.Errorf("block changed to %x", block)
+	}
+	if _, err := rs.Decode(block, []int{0, 3, 6, 9, 12, 15, 1}); err != nil {
+		t.Errorf("with erasures: %v", err)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	block := append(data, 0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55)
+	rs := NewRSDecoder(f, 10)
+	damaged := make([]byte, len(block))
+	for i := 0; i < b.N; i++ {
+		copy(damaged, block)
+		damaged[3] ^= 0x42
+		damaged[20] ^= 0x17
+		if _, err := rs.Decode(damaged, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(block)))
+}
@@ -0,0 +1,151 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// LogoImage returns an Image displaying the code with logo drawn over
+// a centered area of about fraction of its modules, with the shape of the logo.
+// The area is cleared and the logo scaled to fit in it, but the finder,
+// alignment, timing, format and version modules are drawn over it.
+//
+// It returns an error when the data and error correction modules
+// in the area hold more codewords of a block than the level of the code
+// can correct, or when the code only detects errors: it would not scan.
+func (c *Code) LogoImage(logo image.Image, fraction float64) (image.Image, error) {
+	if !(0 < fraction && fraction < 1) {
+		return nil, errors.New("logo fraction must be between 0 and 1")
+	}
+	lb := logo.Bounds()
+	if lb.Empty() {
+		return nil, errors.New("empty logo")
+	}
+	w, h := c.dims()
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	if len(p.Pixel) != h || len(p.Pixel[0]) != w {
+		return nil, errors.New("code version does not match its size")
+	}
+
+	area := logoArea(w, h, float64(lb.Dx())/float64(lb.Dy()), fraction)
+	if err := checkLogo(p, area); err != nil {
+		return nil, err
+	}
+
+	// Draw the code, clear the area and draw the logo over it,
+	// then the function patterns back.
+	code := c.Image()
+	m := image.NewNRGBA(code.Bounds())
+	draw.Draw(m, m.Bounds(), code, image.Point{}, draw.Src)
+	s, q := c.Scale, c.QuietZone
+	px := image.Rect((area.Min.X+q)*s, (area.Min.Y+q)*s, (area.Max.X+q)*s, (area.Max.Y+q)*s)
+	_, bg := c.colors()
+	draw.Draw(m, px, image.NewUniform(bg), image.Point{}, draw.Src)
+	fit := fitRect(lb, px)
+	draw.Draw(m, fit, &scaledImage{logo, lb, fit}, fit.Min, draw.Over)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if !clearable(p.Pixel[y][x]) {
+				r := image.Rect((x+q)*s, (y+q)*s, (x+q+1)*s, (y+q+1)*s)
+				draw.Draw(m, r, code, r.Min, draw.Src)
+			}
+		}
+	}
+	return m, nil
+}
+
+// logoArea returns the modules of a code of w by h modules covered by
+// a centered area of about fraction of them, with the given aspect ratio.
+func logoArea(w, h int, aspect, fraction float64) image.Rectangle {
+	n := fraction * float64(w*h)
+	aw := int(math.Round(math.Sqrt(n * aspect)))
+	if aw > w {
+		aw = w
+	}
+	if aw < 1 {
+		aw = 1
+	}
+	ah := int(math.Round(n / float64(aw)))
+	if ah > h {
+		ah = h
+	}
+	if ah < 1 {
+		ah = 1
+	}
+	x, y := (w-aw)/2, (h-ah)/2
+	return image.Rect(x, y, x+aw, y+ah)
+}
+
+// clearable reports whether a logo may cover the pixel pix.
+func clearable(pix coding.Pixel) bool {
+	switch pix.Role() {
+	case coding.Data, coding.Check, coding.Extra, coding.Unused:
+		return true
+	}
+	return false
+}
+
+// checkLogo checks that the codes laid out by p can still be read
+// with the modules in area cleared: each block has at most as many
+// covered codewords as it can correct.
+func checkLogo(p *coding.Plan, area image.Rectangle) error {
+	budget := p.Version.Correctable(p.Level)
+	if budget == 0 {
+		return errors.New("code can only detect errors, not correct them")
+	}
+	covered := make(map[int]bool)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			switch pix := p.Pixel[y][x]; pix.Role() {
+			case coding.Data, coding.Check:
+				covered[int(pix.Offset()/8)] = true
+			}
+		}
+	}
+	perBlock := make([]int, p.Blocks)
+	for k := range covered {
+		perBlock[p.BlockOf(k)]++
+	}
+	for i, n := range perBlock {
+		if n > budget {
+			return fmt.Errorf("logo covers %d codewords of block %d, more than the %d it can correct", n, i, budget)
+		}
+	}
+	return nil
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of r
+// centered in within.
+func fitRect(r, within image.Rectangle) image.Rectangle {
+	w, h := within.Dx(), within.Dy()
+	if w*r.Dy() > h*r.Dx() {
+		w = h * r.Dx() / r.Dy()
+	} else {
+		h = w * r.Dy() / r.Dx()
+	}
+	min := within.Min.Add(image.Pt((within.Dx()-w)/2, (within.Dy()-h)/2))
+	return image.Rectangle{min, min.Add(image.Pt(w, h))}
+}
+
+// scaledImage is the image m, with bounds from, scaled to the bounds to.
+// It picks the nearest pixel of m.
+type scaledImage struct {
+	m        image.Image
+	from, to image.Rectangle
+}
+
+func (s *scaledImage) ColorModel() color.Model { return s.m.ColorModel() }
+
+func (s *scaledImage) Bounds() image.Rectangle { return s.to }
+
+func (s *scaledImage) At(x, y int) color.Color {
+	sx := s.from.Min.X + (x-s.to.Min.X)*s.from.Dx()/s.to.Dx()
+	sy := s.from.Min.Y + (y-s.to.Min.Y)*s.from.Dy()/s.to.Dy()
+	return s.m.At(sx, sy)
+}
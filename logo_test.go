@@ -0,0 +1,124 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+func TestLogoImage(t *testing.T) {
+	logo := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.NRGBA{0xC0, 0x20, 0x20, 0xFF}), image.Point{}, draw.Src)
+
+	c, err := EncodeWithOptions("https://github.com/cristalhq/qrcode", H, WithMinVersion(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.LogoImage(logo, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Decode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "https://github.com/cristalhq/qrcode" {
+		t.Errorf("have %q", r.Text)
+	}
+
+	// The logo is drawn, but not over the function patterns,
+	// such as the alignment pattern in the middle.
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	s, q := c.Scale, c.QuietZone
+	logoModules := 0
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			at := m.At((x+q)*s+s/2, (y+q)*s+s/2)
+			switch {
+			case !clearable(pix):
+				if !sameColor(at, c.Image().At((x+q)*s, (y+q)*s)) {
+					t.Fatalf("have %v module %d,%d covered", pix.Role(), x, y)
+				}
+			case sameColor(at, logo.At(0, 0)):
+				logoModules++
+			}
+		}
+	}
+	if logoModules == 0 {
+		t.Error("have no logo")
+	}
+}
+
+func TestLogoImageTooLarge(t *testing.T) {
+	logo := image.NewGray(image.Rect(0, 0, 10, 10))
+	c, err := Encode("a logo too large for level L", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.LogoImage(logo, 0.2); err == nil || !strings.Contains(err.Error(), "can correct") {
+		t.Errorf("have %v", err)
+	}
+	if _, err := c.LogoImage(logo, 1.5); err == nil {
+		t.Error("have no error for a fraction above 1")
+	}
+}
+
+func TestCheckLogo(t *testing.T) {
+	testCases := []struct {
+		version coding.Version
+		level   coding.Level
+		limit   int // covered codewords the code can correct
+	}{
+		{1, coding.L, 2},
+		{1, coding.H, 8},
+		{coding.M2, coding.L, 1},
+		{coding.M4, coding.L, 3},
+	}
+	for _, tc := range testCases {
+		p := coding.NewPlan(tc.version, tc.level, 0)
+		size := len(p.Pixel)
+		// Grow an area from the middle of the code, a row at a time,
+		// until it covers more codewords than the limit.
+		seen := make(map[int]bool)
+		ok := false
+		for n := 1; n <= size*size && !ok; n++ {
+			x0, y0 := size/2-1, size/2-1
+			area := image.Rect(x0, y0, x0+(n-1)%(size-x0)+1, y0+(n-1)/(size-x0)+1)
+			if area.Max.Y > size {
+				break
+			}
+			for k := range seen {
+				delete(seen, k)
+			}
+			for y := area.Min.Y; y < area.Max.Y; y++ {
+				for x := area.Min.X; x < area.Max.X; x++ {
+					switch pix := p.Pixel[y][x]; pix.Role() {
+					case coding.Data, coding.Check:
+						seen[int(pix.Offset()/8)] = true
+					}
+				}
+			}
+			switch err := checkLogo(p, area); {
+			case len(seen) <= tc.limit && err != nil:
+				t.Fatalf("%v-%v: have %v for %d codewords", tc.version, tc.level, err, len(seen))
+			case len(seen) > tc.limit:
+				if err == nil {
+					t.Errorf("%v-%v: have no error for %d codewords", tc.version, tc.level, len(seen))
+				}
+				ok = true
+			}
+		}
+		if !ok {
+			t.Errorf("%v-%v: have no area past the limit", tc.version, tc.level)
+		}
+	}
+
+	p := coding.NewPlan(coding.M1, coding.L, 0)
+	if err := checkLogo(p, image.Rect(6, 6, 7, 7)); err == nil || !strings.Contains(err.Error(), "only detect") {
+		t.Errorf("M1: have %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package qrcode
+
+import (
+	"errors"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A MicroVersion denotes a Micro QR version.
+// Micro QR codes have a single position box and fit
+// short data, like serial numbers, into a smaller area.
+type MicroVersion int
+
+const (
+	M1 MicroVersion = 1 // 11x11, up to 5 digits, error detection only
+	M2 MicroVersion = 2 // 13x13, levels L and M
+	M3 MicroVersion = 3 // 15x15, levels L and M
+	M4 MicroVersion = 4 // 17x17, levels L, M and Q
+)
+
+// EncodeMicro returns a Micro QR encoding of text at the given error correction
+// level configured with opts, in the smallest version that fits it.
+// Level L of M1 means error detection only, level H is not available.
+func EncodeMicro(text string, level Level, opts ...Option) (*Code, error) {
+	o, err := newOptions(opts, symbolMicro)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case level == H:
+		return nil, errors.New("Micro QR codes have no level H")
+	case level == Q && o.coding.MaxVersion != coding.M4:
+		return nil, errors.New("level Q requires Micro QR version M4")
+	}
+
+	cc, err := coding.Encode(nil, text, coding.Level(level), &o.coding)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, o), nil
+}
@@ -0,0 +1,46 @@
+package qrcode
+
+import "testing"
+
+func TestEncodeMicro(t *testing.T) {
+	c, err := EncodeMicro("12345", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MicroVersion != M1 || c.Version != 0 || c.Size != 11 {
+		t.Errorf("have version %v size %d, want M1 and 11", c.MicroVersion, c.Size)
+	}
+	if d := c.Image().Bounds().Dx(); d != (11+2*MicroQuietZone)*c.Scale {
+		t.Errorf("have image width %d want %d", d, (11+2*MicroQuietZone)*c.Scale)
+	}
+
+	c, err = EncodeMicro("HELLO", M, WithMicroVersion(M4), WithMask(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MicroVersion != M4 || c.Mask != 2 || c.Size != 17 {
+		t.Errorf("have version %v mask %d size %d, want M4, 2 and 17", c.MicroVersion, c.Mask, c.Size)
+	}
+}
+
+func TestEncodeMicroInvalid(t *testing.T) {
+	testCases := []struct {
+		level Level
+		opts  []Option
+		want  string
+	}{
+		{H, nil, "Micro QR codes have no level H"},
+		{Q, []Option{WithMicroVersion(M3)}, "level Q requires Micro QR version M4"},
+		{L, []Option{WithMicroVersion(M4 + 1)}, "Micro QR version must be between M1 and M4"},
+		{L, []Option{WithVersion(2)}, "use WithMicroVersion to select a Micro QR version"},
+		{L, []Option{WithMask(4)}, "mask must be between 0 and 3"},
+		{L, []Option{WithMicroVersion(M1)}, "text too long to encode as QR"},
+	}
+
+	for _, tc := range testCases {
+		_, err := EncodeMicro("HELLO", tc.level, tc.opts...)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("have %v want %s", err, tc.want)
+		}
+	}
+}
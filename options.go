@@ -0,0 +1,233 @@
+package qrcode
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// DefaultQuietZone is the width of the white border around a code, in modules,
+// required by ISO/IEC 18004.
+const DefaultQuietZone = 4
+
+// MicroQuietZone is the width of the white border around a Micro QR code, in modules,
+// required by ISO/IEC 18004. rMQR codes need the same one, by ISO/IEC 23941.
+const MicroQuietZone = 2
+
+// An Option configures how a code is built.
+type Option func(*options)
+
+type options struct {
+	minVersion   int          // 0 when not set
+	maxVersion   int          // 0 when not set
+	micro        MicroVersion // 0 when not set
+	rect         RectVersion  // 0 when not set
+	maxHeight    int          // 0 when not set
+	mask         int
+	maskSet      bool
+	boost        bool
+	quietZone    int
+	quietZoneSet bool
+	foreground   color.Color // nil when not set
+	background   color.Color // nil when not set
+
+	coding coding.Options
+}
+
+// A symbol is a kind of code: QR, Micro QR or rMQR.
+type symbol int
+
+const (
+	symbolQR symbol = iota
+	symbolMicro
+	symbolRect
+)
+
+// newOptions applies opts to the defaults of the given kind of code.
+func newOptions(opts []Option, kind symbol) (*options, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	minVersion, maxVersion, maxMask := coding.MinVersion, coding.MaxVersion, 7
+	switch kind {
+	case symbolMicro:
+		if o.minVersion != 0 || o.maxVersion != 0 || o.rect != 0 || o.maxHeight != 0 {
+			return nil, errors.New("use WithMicroVersion to select a Micro QR version")
+		}
+		switch {
+		case o.micro == 0:
+			minVersion, maxVersion = coding.M1, coding.M4
+		case M1 <= o.micro && o.micro <= M4:
+			minVersion = coding.M1 + coding.Version(o.micro-M1)
+			maxVersion = minVersion
+		default:
+			return nil, errors.New("Micro QR version must be between M1 and M4")
+		}
+		maxMask = 3
+	case symbolRect:
+		if o.minVersion != 0 || o.maxVersion != 0 || o.micro != 0 {
+			return nil, errors.New("use WithRectVersion to select an rMQR version")
+		}
+		if o.maskSet {
+			return nil, errors.New("rMQR codes have a single mask")
+		}
+		switch {
+		case o.rect == 0:
+			minVersion, maxVersion = coding.R7x43, coding.R17x139
+		case R7x43 <= o.rect && o.rect <= R17x139:
+			minVersion = coding.R7x43 + coding.Version(o.rect-R7x43)
+			maxVersion = minVersion
+		default:
+			return nil, errors.New("rMQR version must be between R7x43 and R17x139")
+		}
+		if o.maxHeight < 0 {
+			return nil, errors.New("max height must not be negative")
+		}
+	default:
+		switch {
+		case o.micro != 0:
+			return nil, errors.New("use EncodeMicro to encode a Micro QR code")
+		case o.rect != 0 || o.maxHeight != 0:
+			return nil, errors.New("use EncodeRect to encode an rMQR code")
+		}
+		if o.minVersion != 0 {
+			minVersion = coding.Version(o.minVersion)
+		}
+		if o.maxVersion != 0 {
+			maxVersion = coding.Version(o.maxVersion)
+		}
+		if minVersion < coding.MinVersion || maxVersion > coding.MaxVersion {
+			return nil, errors.New("version must be between 1 and 40")
+		}
+		if minVersion > maxVersion {
+			return nil, errors.New("min version is greater than max version")
+		}
+	}
+
+	mask := coding.AutoMask
+	if o.maskSet {
+		if o.mask < 0 || o.mask > maxMask {
+			if kind == symbolMicro {
+				return nil, errors.New("mask must be between 0 and 3")
+			}
+			return nil, errors.New("mask must be between 0 and 7")
+		}
+		mask = coding.Mask(o.mask)
+	}
+
+	switch {
+	case !o.quietZoneSet && kind != symbolQR:
+		o.quietZone = MicroQuietZone
+	case !o.quietZoneSet:
+		o.quietZone = DefaultQuietZone
+	case o.quietZone < 0:
+		return nil, errors.New("quiet zone must not be negative")
+	}
+
+	o.coding = coding.Options{
+		MinVersion: minVersion,
+		MaxVersion: maxVersion,
+		Mask:       mask,
+		Boost:      o.boost,
+		MaxHeight:  o.maxHeight,
+	}
+	return o, nil
+}
+
+// WithVersion makes the code use exactly the given version, from 1 to 40.
+// Encoding fails if the data does not fit it.
+func WithVersion(version int) Option {
+	return func(o *options) {
+		o.minVersion = version
+		o.maxVersion = version
+	}
+}
+
+// WithMinVersion makes the code at least the given version.
+func WithMinVersion(version int) Option {
+	return func(o *options) {
+		o.minVersion = version
+	}
+}
+
+// WithMaxVersion makes the code at most the given version.
+func WithMaxVersion(version int) Option {
+	return func(o *options) {
+		o.maxVersion = version
+	}
+}
+
+// WithMicroVersion makes the Micro QR code use exactly the given version.
+// Encoding fails if the data does not fit it.
+func WithMicroVersion(version MicroVersion) Option {
+	return func(o *options) {
+		o.micro = version
+	}
+}
+
+// WithRectVersion makes the rMQR code use exactly the given version.
+// Encoding fails if the data does not fit it.
+func WithRectVersion(version RectVersion) Option {
+	return func(o *options) {
+		o.rect = version
+	}
+}
+
+// WithMaxHeight makes the rMQR code at most the given number of modules high.
+func WithMaxHeight(modules int) Option {
+	return func(o *options) {
+		o.maxHeight = modules
+	}
+}
+
+// WithMask applies the given data mask pattern, from 0 to 7 (0 to 3 for Micro QR),
+// instead of the one with the lowest penalty score.
+func WithMask(mask int) Option {
+	return func(o *options) {
+		o.mask = mask
+		o.maskSet = true
+	}
+}
+
+// WithBoostLevel raises the error correction level to the highest one
+// that still fits the data into the chosen version.
+func WithBoostLevel() Option {
+	return func(o *options) {
+		o.boost = true
+	}
+}
+
+// WithQuietZone sets the width of the white border around the code, in modules.
+// The default is DefaultQuietZone, or MicroQuietZone for Micro QR and rMQR codes.
+func WithQuietZone(modules int) Option {
+	return func(o *options) {
+		o.quietZone = modules
+		o.quietZoneSet = true
+	}
+}
+
+// WithColors sets the colors of the black and white pixels of the code
+// in Image and PNG. The background may be transparent.
+func WithColors(foreground, background color.Color) Option {
+	return func(o *options) {
+		o.foreground = foreground
+		o.background = background
+	}
+}
+
+// EncodeWithOptions returns an encoding of text at the given error correction level
+// configured with opts.
+func EncodeWithOptions(text string, level Level, opts ...Option) (*Code, error) {
+	o, err := newOptions(opts, symbolQR)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := coding.Encode(nil, text, coding.Level(level), &o.coding)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, o), nil
+}
@@ -0,0 +1,68 @@
+package qrcode
+
+import "testing"
+
+func TestEncodeWithOptions(t *testing.T) {
+	c, err := EncodeWithOptions("hello", L, WithVersion(5), WithMask(3), WithQuietZone(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 5 || c.Size != 37 {
+		t.Errorf("have version %d size %d, want 5 and 37", c.Version, c.Size)
+	}
+	if c.Mask != 3 {
+		t.Errorf("have mask %d want 3", c.Mask)
+	}
+	if d := c.Image().Bounds().Dx(); d != (37+4)*c.Scale {
+		t.Errorf("have image width %d want %d", d, (37+4)*c.Scale)
+	}
+
+	c, err = EncodeWithOptions("hello", L, WithMinVersion(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 3 {
+		t.Errorf("have version %d want 3", c.Version)
+	}
+}
+
+func TestEncodeWithOptionsBoost(t *testing.T) {
+	c, err := EncodeWithOptions("12345", L, WithBoostLevel())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 1 || c.Level != H {
+		t.Errorf("have version %d level %d, want 1 and H", c.Version, c.Level)
+	}
+
+	// 41 digits fill version 1 at level L.
+	c, err = EncodeWithOptions("12345678901234567890123456789012345678901", L, WithBoostLevel())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 1 || c.Level != L {
+		t.Errorf("have version %d level %d, want 1 and L", c.Version, c.Level)
+	}
+}
+
+func TestEncodeWithOptionsInvalid(t *testing.T) {
+	testCases := []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{WithVersion(41)}, "version must be between 1 and 40"},
+		{[]Option{WithMinVersion(-1)}, "version must be between 1 and 40"},
+		{[]Option{WithMicroVersion(M2)}, "use EncodeMicro to encode a Micro QR code"},
+		{[]Option{WithMinVersion(10), WithMaxVersion(5)}, "min version is greater than max version"},
+		{[]Option{WithMask(8)}, "mask must be between 0 and 7"},
+		{[]Option{WithQuietZone(-1)}, "quiet zone must not be negative"},
+		{[]Option{WithMaxVersion(1)}, "text too long to encode as QR"},
+	}
+
+	for _, tc := range testCases {
+		_, err := EncodeWithOptions("https://github.com/cristalhq/qrcode", M, tc.opts...)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("have %v want %s", err, tc.want)
+		}
+	}
+}
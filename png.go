@@ -8,6 +8,8 @@ import (
 )
 
 // PNG returns a PNG image displaying the code.
+// A code with a Foreground or a Background color is
+// a two-color palette image, with transparency if they have some.
 //
 // PNG uses a custom encoder tailored to QR codes.
 // Its compressed size is about 2x away from optimal,
@@ -33,7 +35,7 @@ var (
 
 func (w *pngWriter) encode(c *Code) []byte {
 	scale := c.Scale
-	siz := c.Size
+	wid, hgt := c.dims()
 
 	w.buf.Reset()
 
@@ -41,10 +43,16 @@ func (w *pngWriter) encode(c *Code) []byte {
 	w.buf.Write(pngHeader)
 
 	// Header block
-	binary.BigEndian.PutUint32(w.tmp[0:4], uint32((siz+8)*scale))
-	binary.BigEndian.PutUint32(w.tmp[4:8], uint32((siz+8)*scale))
+	q := c.QuietZone
+	binary.BigEndian.PutUint32(w.tmp[0:4], uint32((wid+2*q)*scale))
+	binary.BigEndian.PutUint32(w.tmp[4:8], uint32((hgt+2*q)*scale))
+	fg, bg := c.colors()
+	palette := c.Foreground != nil || c.Background != nil
 	w.tmp[8] = 1 // 1-bit
 	w.tmp[9] = 0 // gray
+	if palette {
+		w.tmp[9] = 3 // palette
+	}
 	w.tmp[10] = 0
 	w.tmp[11] = 0
 	w.tmp[12] = 0
@@ -53,6 +61,14 @@ func (w *pngWriter) encode(c *Code) []byte {
 	// Comment
 	w.writeChunk("tEXt", comment)
 
+	// Palette: black pixels are 0, white ones 1.
+	if palette {
+		w.writeChunk("PLTE", []byte{fg.R, fg.G, fg.B, bg.R, bg.G, bg.B})
+		if fg.A != 0xFF || bg.A != 0xFF {
+			w.writeChunk("tRNS", []byte{fg.A, bg.A})
+		}
+	}
+
 	// Data
 	w.zlib.writeCode(c)
 	w.writeChunk("IDAT", w.zlib.bytes.Bytes())
@@ -90,15 +106,16 @@ type bitWriter struct {
 	adler32 adigest
 }
 
-func (b *bitWriter) writeCode(c *Code) {
-	const ftNone = 0
+// ftNone is the PNG filter type of rows stored as they are.
+const ftNone = 0
 
+func (b *bitWriter) writeCode(c *Code) {
 	b.adler32.Reset()
 	b.bytes.Reset()
 	b.nbit = 0
 
 	scale := c.Scale
-	siz := c.Size
+	w, h := c.dims()
 
 	// zlib header
 	b.tmp[0] = 0x78
@@ -111,26 +128,17 @@ func (b *bitWriter) writeCode(c *Code) {
 	b.writeBits(1, 2, false) // compressed, fixed Huffman tables
 
 	// White border.
-	// First row.
-	b.byte(ftNone)
-	n := (scale*(siz+8) + 7) / 8
-	b.byte(255)
-	b.repeat(n-1, 1)
-	// 4*scale rows total.
-	b.repeat((4*scale-1)*(1+n), 1+n)
-
-	for i := 0; i < 4*scale; i++ {
-		b.adler32.WriteNByte(ftNone, 1)
-		b.adler32.WriteNByte(255, n)
-	}
+	q := c.QuietZone
+	n := (scale*(w+2*q) + 7) / 8
+	b.whiteRows(q*scale, n)
 
 	row := make([]byte, 1+n)
-	for y := 0; y < siz; y++ {
+	for y := 0; y < h; y++ {
 		row[0] = ftNone
 		j := 1
 		var z uint8
 		nz := 0
-		for x := -4; x < siz+4; x++ {
+		for x := -q; x < w+q; x++ {
 			// Raw data.
 			for i := 0; i < scale; i++ {
 				z <<= 1
@@ -145,31 +153,23 @@ func (b *bitWriter) writeCode(c *Code) {
 				}
 			}
 		}
-		if j < len(row) {
-			row[j] = z
+		if nz > 0 {
+			row[j] = z << uint(8-nz)
 		}
 		for _, z := range row {
 			b.byte(z)
 		}
 
 		// Scale-1 copies.
-		b.repeat((scale-1)*(1+n), 1+n)
+		if scale > 1 {
+			b.repeat((scale-1)*(1+n), 1+n)
+		}
 
 		b.adler32.WriteN(row, scale)
 	}
 
 	// White border.
-	// First row.
-	b.byte(ftNone)
-	b.byte(255)
-	b.repeat(n-1, 1)
-	// 4*scale rows total.
-	b.repeat((4*scale-1)*(1+n), 1+n)
-
-	for i := 0; i < 4*scale; i++ {
-		b.adler32.WriteNByte(ftNone, 1)
-		b.adler32.WriteNByte(255, n)
-	}
+	b.whiteRows(q*scale, n)
 
 	// End of block.
 	b.hcode(256)
@@ -180,6 +180,32 @@ func (b *bitWriter) writeCode(c *Code) {
 	b.bytes.Write(b.tmp[0:4])
 }
 
+// whiteRows writes rows white rows of n bytes, each after its filter type.
+func (b *bitWriter) whiteRows(rows, n int) {
+	if rows == 0 {
+		return
+	}
+	// First row.
+	b.byte(ftNone)
+	b.byte(255)
+	if n-1 >= 3 {
+		b.repeat(n-1, 1)
+	} else {
+		for i := 1; i < n; i++ {
+			b.byte(255)
+		}
+	}
+	// The other rows are copies of it.
+	if rows > 1 {
+		b.repeat((rows-1)*(1+n), 1+n)
+	}
+
+	for i := 0; i < rows; i++ {
+		b.adler32.WriteNByte(ftNone, 1)
+		b.adler32.WriteNByte(255, n)
+	}
+}
+
 func (b *bitWriter) writeBits(bit uint32, nbit uint, rev bool) {
 	// reverse, for huffman codes
 	if rev {
@@ -19,24 +19,85 @@ func TestPNG(t *testing.T) {
 	if true {
 		os.WriteFile("x.png", pngdat, 0o666)
 	}
+	checkPNG(t, c, pngdat)
+}
+
+func TestPNGQuietZone(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		opts  []Option
+		scale int
+	}{
+		{"no quiet zone", []Option{WithQuietZone(0)}, 3},
+		{"one module", []Option{WithQuietZone(1)}, 1},
+		{"wide", []Option{WithQuietZone(10)}, 2},
+		{"rMQR", []Option{WithRectVersion(R7x43), WithQuietZone(0)}, 1},
+	} {
+		encode := EncodeWithOptions
+		if tc.text == "rMQR" {
+			encode = EncodeRect
+		}
+		c, err := encode(tc.text, M, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Scale = tc.scale
+		checkPNG(t, c, c.PNG())
+	}
+}
 
+func TestPNGColors(t *testing.T) {
+	for _, tc := range []struct {
+		fg, bg color.Color
+		model  color.Model
+	}{
+		{nil, nil, color.GrayModel},
+		{color.NRGBA{0, 0, 0x80, 0xFF}, nil, color.NRGBAModel},
+		{color.NRGBA{0, 0, 0x80, 0xFF}, color.Transparent, color.NRGBAModel},
+		{color.RGBA{0x20, 0x40, 0x60, 0x80}, color.White, color.NRGBAModel},
+	} {
+		c, err := EncodeWithOptions("colors", M, WithColors(tc.fg, tc.bg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pngdat := c.PNG()
+		m, err := png.Decode(bytes.NewReader(pngdat))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, palette := m.(*image.Paletted)
+		if palette != (tc.fg != nil || tc.bg != nil) {
+			t.Errorf("%v on %v: have a %T", tc.fg, tc.bg, m)
+		}
+		if c.Image().ColorModel() != tc.model {
+			t.Errorf("%v on %v: have another image color model", tc.fg, tc.bg)
+		}
+		checkPNG(t, c, pngdat)
+	}
+}
+
+// checkPNG checks that the PNG image pngdat displays c.
+func checkPNG(t *testing.T, c *Code, pngdat []byte) {
+	t.Helper()
 	m, err := png.Decode(bytes.NewBuffer(pngdat))
 	if err != nil {
 		t.Fatal(err)
 	}
-	gm := m.(*image.Gray)
-
-	scale := c.Scale
-	siz := c.Size
+	scale, q := c.Scale, c.QuietZone
+	w, h := c.dims()
+	fg, bg := c.colors()
+	if b := m.Bounds(); b.Dx() != scale*(w+2*q) || b.Dy() != scale*(h+2*q) {
+		t.Fatalf("have size %v want %dx%d", b.Size(), scale*(w+2*q), scale*(h+2*q))
+	}
 	nbad := 0
-	for y := 0; y < scale*(8+siz); y++ {
-		for x := 0; x < scale*(8+siz); x++ {
-			v := byte(255)
-			if c.IsBlack(x/scale-4, y/scale-4) {
-				v = 0
+	for y := 0; y < scale*(h+2*q); y++ {
+		for x := 0; x < scale*(w+2*q); x++ {
+			v := bg
+			if c.IsBlack(x/scale-q, y/scale-q) {
+				v = fg
 			}
-			if gv := gm.At(x, y).(color.Gray).Y; gv != v {
-				t.Errorf("%d,%d = %d, want %d", x, y, gv, v)
+			if mv := color.NRGBAModel.Convert(m.At(x, y)); mv != v {
+				t.Errorf("%d,%d = %v, want %v", x, y, mv, v)
 				nbad++
 				if nbad >= 20 {
 					t.Fatalf("too many bad pixels")
@@ -1,6 +1,7 @@
 package qrcode
 
 import (
+	"errors"
 	"image"
 	"image/color"
 
@@ -23,61 +24,198 @@ func Encode(text string, level Level) (*Code, error) {
 	return EncodeInto(nil, text, level)
 }
 
+// EncodeInto is like Encode but reuses bitmap for the code pixels when possible.
+//
+// Text with non-ASCII characters stored as 8-bit data is prefixed
+// with the UTF-8 ECI so scanners do not read it as ISO-8859-1.
 func EncodeInto(bitmap []byte, text string, level Level) (*Code, error) {
-	cc, err := coding.Encode(bitmap, text, coding.Level(level))
+	cc, err := coding.Encode(bitmap, text, coding.Level(level), nil)
 	if err != nil {
 		return nil, err
 	}
+	return newCode(cc, nil), nil
+}
+
+// An ECI is an Extended Channel Interpretation assignment number.
+// It tells the scanner which character set the 8-bit data uses.
+type ECI int
+
+// ECI assignment numbers of common character sets.
+const (
+	ISO8859_1   ECI = 3
+	ISO8859_2   ECI = 4
+	ISO8859_3   ECI = 5
+	ISO8859_4   ECI = 6
+	ISO8859_5   ECI = 7
+	ISO8859_6   ECI = 8
+	ISO8859_7   ECI = 9
+	ISO8859_8   ECI = 10
+	ISO8859_9   ECI = 11
+	ISO8859_10  ECI = 12
+	ISO8859_11  ECI = 13
+	ISO8859_13  ECI = 15
+	ISO8859_14  ECI = 16
+	ISO8859_15  ECI = 17
+	ISO8859_16  ECI = 18
+	ShiftJIS    ECI = 20
+	Windows1250 ECI = 21
+	Windows1251 ECI = 22
+	Windows1252 ECI = 23
+	Windows1256 ECI = 24
+	UTF16BE     ECI = 25
+	UTF8        ECI = 26
+	ASCII       ECI = 27
+	Big5        ECI = 28
+	GB18030     ECI = 29
+	EUCKR       ECI = 30
+)
+
+// EncodeECI returns an encoding of data in the character set eci.
+// The data must already be encoded in that character set,
+// it is stored as 8-bit data after the ECI header.
+func EncodeECI(data string, eci ECI, level Level) (*Code, error) {
+	if eci < 0 || eci > 999999 {
+		return nil, errors.New("invalid ECI assignment number")
+	}
+	enc := coding.Segments{coding.ECI(eci), coding.String(data)}
+	cc, err := coding.EncodeData(nil, enc, coding.Level(level), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, nil), nil
+}
 
+func newCode(cc *coding.Code, o *options) *Code {
 	code := &Code{
-		Bitmap: cc.Bitmap,
-		Size:   cc.Size,
-		Stride: cc.Stride,
-		Scale:  8,
+		Bitmap:    cc.Bitmap,
+		Size:      cc.Size,
+		Width:     cc.Width,
+		Height:    cc.Height,
+		Stride:    cc.Stride,
+		Scale:     8,
+		QuietZone: DefaultQuietZone,
+		Version:   int(cc.Version),
+		Level:     Level(cc.Level),
+		Mask:      int(cc.Mask),
+		Penalty:   cc.Penalty(),
+	}
+	if cc.Version.Micro() {
+		code.Version = 0
+		code.MicroVersion = MicroVersion(cc.Version-coding.M1) + M1
+		code.QuietZone = MicroQuietZone
+	}
+	if cc.Version.Rect() {
+		code.Version = 0
+		code.RectVersion = RectVersion(cc.Version-coding.R7x43) + R7x43
+		code.QuietZone = MicroQuietZone
+	}
+	if o != nil {
+		code.QuietZone = o.quietZone
+		code.Foreground, code.Background = o.foreground, o.background
 	}
-	return code, nil
+	return code
 }
 
-// A Code is a square pixel grid.
+// A Code is a square pixel grid, or a rectangular one for rMQR codes.
 // It implements image.Image and direct PNG encoding.
 type Code struct {
-	Bitmap []byte // 1 is black, 0 is white
-	Size   int    // number of pixels on a side
-	Stride int    // number of bytes per row
-	Scale  int    // number of image pixels per QR pixel
+	Bitmap    []byte // 1 is black, 0 is white
+	Size      int    // number of pixels on a side, the width for rMQR codes
+	Width     int    // number of pixels in a row, Size if 0
+	Height    int    // number of rows, Size if 0
+	Stride    int    // number of bytes per row
+	Scale     int    // number of image pixels per QR pixel
+	QuietZone int    // number of white QR pixels around the code in Image, PNG, SVG and ASCII
+	Version   int    // version, from 1 to 40, 0 for Micro QR and rMQR codes
+	Level     Level  // error correction level
+	Mask      int    // data mask pattern, from 0 to 7 (0 to 3 for Micro QR, 4 for rMQR)
+	Penalty   int    // mask penalty score, lower scans better
+
+	MicroVersion MicroVersion // Micro QR version, 0 for other codes
+	RectVersion  RectVersion  // rMQR version, 0 for other codes
+
+	// Foreground and Background are the colors of the black and white
+	// pixels in Image and PNG, black and white when nil.
+	Foreground, Background color.Color
+}
+
+// colors returns the colors of the black and white pixels of c.
+func (c *Code) colors() (fg, bg color.NRGBA) {
+	fg, bg = color.NRGBA{A: 0xFF}, color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	if c.Foreground != nil {
+		fg = color.NRGBAModel.Convert(c.Foreground).(color.NRGBA)
+	}
+	if c.Background != nil {
+		bg = color.NRGBAModel.Convert(c.Background).(color.NRGBA)
+	}
+	return fg, bg
+}
+
+// dims returns the width and the height of the code.
+func (c *Code) dims() (w, h int) {
+	w, h = c.Width, c.Height
+	if w == 0 {
+		w = c.Size
+	}
+	if h == 0 {
+		h = c.Size
+	}
+	return w, h
+}
+
+// version returns the version of the code, as the coding package numbers it.
+func (c *Code) version() coding.Version {
+	switch {
+	case c.MicroVersion != 0:
+		return coding.M1 + coding.Version(c.MicroVersion-M1)
+	case c.RectVersion != 0:
+		return coding.R7x43 + coding.Version(c.RectVersion-R7x43)
+	}
+	return coding.Version(c.Version)
 }
 
 // IsBlack returns true if the pixel at (x,y) is black.
 func (c *Code) IsBlack(x, y int) bool {
 	idx := y*c.Stride + x/8
 	mask := byte(1 << uint(7-x&7))
+	w, h := c.dims()
 
-	return 0 <= x && x < c.Size &&
-		0 <= y && y < c.Size &&
+	return 0 <= x && x < w &&
+		0 <= y && y < h &&
 		c.Bitmap[idx]&mask != 0
 }
 
 // Image returns an Image displaying the code.
 func (c *Code) Image() image.Image {
-	return &codeImage{c}
+	m := &codeImage{Code: c, fg: blackColor, bg: whiteColor}
+	if c.Foreground != nil || c.Background != nil {
+		m.fg, m.bg = c.colors()
+	}
+	return m
 }
 
 // codeImage implements image.Image.
-type codeImage struct{ *Code }
+type codeImage struct {
+	*Code
+	fg, bg color.Color
+}
 
 func (c *codeImage) Bounds() image.Rectangle {
-	d := (c.Size + 8) * c.Scale
-	return image.Rect(0, 0, d, d)
+	w, h := c.dims()
+	return image.Rect(0, 0, (w+2*c.QuietZone)*c.Scale, (h+2*c.QuietZone)*c.Scale)
 }
 
 func (c *codeImage) At(x, y int) color.Color {
-	if c.IsBlack(x/c.Scale-4, y/c.Scale-4) {
-		return blackColor
+	if c.IsBlack(x/c.Scale-c.QuietZone, y/c.Scale-c.QuietZone) {
+		return c.fg
 	}
-	return whiteColor
+	return c.bg
 }
 
 func (c *codeImage) ColorModel() color.Model {
+	if c.Foreground != nil || c.Background != nil {
+		return color.NRGBAModel
+	}
 	return color.GrayModel
 }
 
@@ -0,0 +1,66 @@
+package qrcode
+
+import (
+	"errors"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A RectVersion denotes a Rectangular Micro QR (rMQR) version,
+// named by the height and the width of the code in modules.
+// rMQR codes fit data into narrow strips, from 7 to 17 modules high.
+type RectVersion int
+
+const (
+	R7x43 RectVersion = iota + 1
+	R7x59
+	R7x77
+	R7x99
+	R7x139
+	R9x43
+	R9x59
+	R9x77
+	R9x99
+	R9x139
+	R11x27
+	R11x43
+	R11x59
+	R11x77
+	R11x99
+	R11x139
+	R13x27
+	R13x43
+	R13x59
+	R13x77
+	R13x99
+	R13x139
+	R15x43
+	R15x59
+	R15x77
+	R15x99
+	R15x139
+	R17x43
+	R17x59
+	R17x77
+	R17x99
+	R17x139
+)
+
+// EncodeRect returns an rMQR encoding of text at the given error correction
+// level configured with opts, in the version with the smallest area that fits it.
+// rMQR codes have only levels M and H, and a single mask.
+func EncodeRect(text string, level Level, opts ...Option) (*Code, error) {
+	o, err := newOptions(opts, symbolRect)
+	if err != nil {
+		return nil, err
+	}
+	if level != M && level != H {
+		return nil, errors.New("rMQR codes have only levels M and H")
+	}
+
+	cc, err := coding.Encode(nil, text, coding.Level(level), &o.coding)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, o), nil
+}
@@ -0,0 +1,65 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestEncodeRect(t *testing.T) {
+	c, err := EncodeRect("HELLO WORLD", H, WithMaxHeight(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RectVersion != R7x77 || c.Version != 0 || c.Width != 77 || c.Height != 7 {
+		t.Errorf("have version %d size %dx%d, want R7x77", c.RectVersion, c.Height, c.Width)
+	}
+
+	b := c.Image().Bounds()
+	if b.Dx() != (77+2*MicroQuietZone)*c.Scale || b.Dy() != (7+2*MicroQuietZone)*c.Scale {
+		t.Errorf("have image size %v", b.Size())
+	}
+
+	m, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != b {
+		t.Errorf("have PNG size %v", m.Bounds().Size())
+	}
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			q := MicroQuietZone
+			r, _, _, _ := m.At((x+q)*c.Scale, (y+q)*c.Scale).RGBA()
+			if (r == 0) != c.IsBlack(x, y) {
+				t.Fatalf("PNG pixel (%d, %d) does not match the code", x, y)
+			}
+		}
+	}
+}
+
+func TestEncodeRectInvalid(t *testing.T) {
+	testCases := []struct {
+		level Level
+		opts  []Option
+		want  string
+	}{
+		{L, nil, "rMQR codes have only levels M and H"},
+		{M, []Option{WithVersion(2)}, "use WithRectVersion to select an rMQR version"},
+		{M, []Option{WithMask(4)}, "rMQR codes have a single mask"},
+		{M, []Option{WithRectVersion(R17x139 + 1)}, "rMQR version must be between R7x43 and R17x139"},
+		{M, []Option{WithMaxHeight(-1)}, "max height must not be negative"},
+		{M, []Option{WithMaxHeight(5)}, "text too long to encode as QR"},
+	}
+
+	for _, tc := range testCases {
+		_, err := EncodeRect("HELLO", tc.level, tc.opts...)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("have %v want %s", err, tc.want)
+		}
+	}
+
+	if _, err := EncodeWithOptions("HELLO", M, WithRectVersion(R7x43)); err == nil {
+		t.Error("have no error for an rMQR option")
+	}
+}
@@ -0,0 +1,118 @@
+package qrcode
+
+import (
+	"fmt"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A Mode is the data encoding mode of a Segment.
+type Mode int
+
+const (
+	ModeNumeric      Mode = iota + 1 // decimal digits 0-9
+	ModeAlphanumeric                 // 0-9, A-Z, space and $%*+-./:
+	ModeByte                         // arbitrary 8-bit data
+	ModeKanji                        // double-byte Shift JIS characters
+	ModeECI                          // character set switch
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNumeric:
+		return "numeric"
+	case ModeAlphanumeric:
+		return "alphanumeric"
+	case ModeByte:
+		return "byte"
+	case ModeKanji:
+		return "kanji"
+	case ModeECI:
+		return "eci"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// A Segment is a run of data encoded in a single mode.
+// A QR code holds a sequence of segments.
+type Segment struct {
+	Mode Mode
+	Data []byte // segment data, UTF-8 text for ModeKanji
+	ECI  ECI    // assignment number for ModeECI
+}
+
+// NumericSegment returns a segment of decimal digits.
+func NumericSegment(digits string) Segment {
+	return Segment{Mode: ModeNumeric, Data: []byte(digits)}
+}
+
+// AlphanumericSegment returns a segment of characters
+// from the QR alphanumeric set: 0-9, A-Z, space and $%*+-./:
+func AlphanumericSegment(text string) Segment {
+	return Segment{Mode: ModeAlphanumeric, Data: []byte(text)}
+}
+
+// ByteSegment returns a segment of arbitrary binary data.
+func ByteSegment(data []byte) Segment {
+	return Segment{Mode: ModeByte, Data: data}
+}
+
+// KanjiSegment returns a segment of Kanji characters.
+// The text is UTF-8, every character must have a Shift JIS mapping.
+func KanjiSegment(text string) Segment {
+	return Segment{Mode: ModeKanji, Data: []byte(text)}
+}
+
+// ECISegment returns a segment that switches the character set
+// of the following byte segments to eci.
+func ECISegment(eci ECI) Segment {
+	return Segment{Mode: ModeECI, ECI: eci}
+}
+
+// EncodeSegments returns an encoding of segs, in order,
+// at the given error correction level configured with opts.
+func EncodeSegments(segs []Segment, level Level, opts ...Option) (*Code, error) {
+	o, err := newOptions(opts, symbolQR)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := segmentsEncoding(segs)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := coding.EncodeData(nil, enc, coding.Level(level), &o.coding)
+	if err != nil {
+		return nil, err
+	}
+	return newCode(cc, o), nil
+}
+
+func segmentsEncoding(segs []Segment) (coding.Segments, error) {
+	enc := make(coding.Segments, 0, len(segs))
+	for _, seg := range segs {
+		var e coding.Encoding
+		switch seg.Mode {
+		case ModeNumeric:
+			e = coding.Num(seg.Data)
+		case ModeAlphanumeric:
+			e = coding.Alpha(seg.Data)
+		case ModeByte:
+			e = coding.String(seg.Data)
+		case ModeKanji:
+			e = coding.Kanji(seg.Data)
+		case ModeECI:
+			if seg.ECI < 0 {
+				return nil, fmt.Errorf("invalid %v segment", seg.Mode)
+			}
+			e = coding.ECI(seg.ECI)
+		default:
+			return nil, fmt.Errorf("unknown segment mode %v", seg.Mode)
+		}
+		if !e.Check() {
+			return nil, fmt.Errorf("invalid %v segment", seg.Mode)
+		}
+		enc = append(enc, e)
+	}
+	return enc, nil
+}
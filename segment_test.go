@@ -0,0 +1,39 @@
+package qrcode
+
+import "testing"
+
+func TestEncodeSegments(t *testing.T) {
+	segs := []Segment{
+		ECISegment(UTF8),
+		NumericSegment("0123456789"),
+		ByteSegment([]byte{0x00, 0xff, 0x10}),
+		KanjiSegment("コード"),
+	}
+	c, err := EncodeSegments(segs, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 25 {
+		t.Errorf("have size %d want 25", c.Size)
+	}
+}
+
+func TestEncodeSegmentsInvalid(t *testing.T) {
+	testCases := []struct {
+		seg  Segment
+		want string
+	}{
+		{NumericSegment("12a"), "invalid numeric segment"},
+		{AlphanumericSegment("abc"), "invalid alphanumeric segment"},
+		{KanjiSegment("abc"), "invalid kanji segment"},
+		{ECISegment(1000000), "invalid eci segment"},
+		{Segment{}, "unknown segment mode Mode(0)"},
+	}
+
+	for _, tc := range testCases {
+		_, err := EncodeSegments([]Segment{tc.seg}, L)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("have %v want %s", err, tc.want)
+		}
+	}
+}
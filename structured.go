@@ -0,0 +1,27 @@
+package qrcode
+
+import (
+	"errors"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// EncodeSplit returns an encoding of text as a Structured Append sequence
+// of up to 16 codes, each of version maxVersion or smaller.
+// Readers that support Structured Append put the text back together.
+// When text fits into a single code, EncodeSplit returns just that code.
+func EncodeSplit(text string, level Level, maxVersion int) ([]*Code, error) {
+	if maxVersion < int(coding.MinVersion) || maxVersion > int(coding.MaxVersion) {
+		return nil, errors.New("version must be between 1 and 40")
+	}
+	ccs, err := coding.EncodeSplit(text, coding.Level(level), coding.Version(maxVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]*Code, len(ccs))
+	for i, cc := range ccs {
+		codes[i] = newCode(cc, nil)
+	}
+	return codes, nil
+}
@@ -0,0 +1,286 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/cristalhq/qrcode/internal/coding"
+)
+
+// A Shape is the shape of a module, or of a part of a finder pattern.
+type Shape int
+
+const (
+	ShapeSquare  Shape = iota // squares, as in plain codes
+	ShapeCircle               // circles, or dots for modules
+	ShapeRounded              // squares with rounded corners
+	ShapeLiquid               // modules joined to their dark neighbors, rounded elsewhere
+)
+
+// A Style describes how StyledImage and StyledSVG draw a code.
+//
+// Module is the shape of the data, error correction, format
+// and version modules. The outer ring and the inner eye of each
+// finder pattern have their own shapes and colors, the foreground
+// of the code when nil. The alignment and timing patterns,
+// the sub-finder of rMQR codes and the shapes of the single finder
+// pattern of Micro QR and rMQR codes stay square so that the code
+// can still be located and read.
+type Style struct {
+	Module      Shape
+	FinderOuter Shape
+	FinderInner Shape
+
+	FinderOuterColor color.Color
+	FinderInnerColor color.Color
+}
+
+// roundRect is a rectangle with rounded corners,
+// with radii from the top left corner clockwise.
+type roundRect struct {
+	x, y, w, h float64
+	r          [4]float64
+}
+
+// shapeRect returns the rectangle at (x, y) with a side of size
+// drawn in shape s, the shape of a box for ShapeLiquid.
+func shapeRect(s Shape, x, y, size float64) roundRect {
+	var r float64
+	switch s {
+	case ShapeCircle:
+		r = size / 2
+	case ShapeRounded, ShapeLiquid:
+		r = size / 4
+	}
+	return roundRect{x, y, size, size, [4]float64{r, r, r, r}}
+}
+
+// contains reports whether the point (px, py) is inside rr.
+func (rr *roundRect) contains(px, py float64) bool {
+	if px < rr.x || px >= rr.x+rr.w || py < rr.y || py >= rr.y+rr.h {
+		return false
+	}
+	// The center of the circle of the corner nearest to the point.
+	left, top := px < rr.x+rr.w/2, py < rr.y+rr.h/2
+	var r float64
+	switch {
+	case left && top:
+		r = rr.r[0]
+	case top:
+		r = rr.r[1]
+	case left:
+		r = rr.r[3]
+	default:
+		r = rr.r[2]
+	}
+	cx, cy := rr.x+r, rr.y+r
+	if !left {
+		cx = rr.x + rr.w - r
+	}
+	if !top {
+		cy = rr.y + rr.h - r
+	}
+	if left && px >= cx || !left && px <= cx || top && py >= cy || !top && py <= cy {
+		return true // not in the corner
+	}
+	return (px-cx)*(px-cx)+(py-cy)*(py-cy) <= r*r
+}
+
+// path writes rr as a closed SVG path.
+func (rr *roundRect) path(b *bytes.Buffer) {
+	x0, y0, x1, y1 := rr.x, rr.y, rr.x+rr.w, rr.y+rr.h
+	b.WriteByte('M')
+	writeNums(b, x0+rr.r[0], y0)
+	b.WriteByte('H')
+	writeNums(b, x1-rr.r[1])
+	arc(b, rr.r[1], x1, y0+rr.r[1])
+	b.WriteByte('V')
+	writeNums(b, y1-rr.r[2])
+	arc(b, rr.r[2], x1-rr.r[2], y1)
+	b.WriteByte('H')
+	writeNums(b, x0+rr.r[3])
+	arc(b, rr.r[3], x0, y1-rr.r[3])
+	b.WriteByte('V')
+	writeNums(b, y0+rr.r[0])
+	arc(b, rr.r[0], x0+rr.r[0], y0)
+	b.WriteByte('Z')
+}
+
+// arc writes a clockwise quarter circle of radius r to (x, y), if any.
+func arc(b *bytes.Buffer, r, x, y float64) {
+	if r == 0 {
+		return
+	}
+	b.WriteByte('A')
+	writeNums(b, r, r)
+	b.WriteString(" 0 0 1 ")
+	writeNums(b, x, y)
+}
+
+// writeNums writes the numbers xs separated by spaces.
+func writeNums(b *bytes.Buffer, xs ...float64) {
+	for i, x := range xs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	}
+}
+
+// A styler lays out a code drawn in a style.
+type styler struct {
+	c            *Code
+	s            *Style
+	plan         *coding.Plan // nil when it does not match the code
+	eyes         []image.Point
+	outer, inner Shape // shapes of the finder patterns, square for a single one
+}
+
+func newStyler(c *Code, s *Style) *styler {
+	st := &styler{c: c, s: s}
+	if s == nil {
+		st.s = &Style{}
+	}
+	w, h := c.dims()
+	p := coding.NewPlan(c.version(), coding.Level(c.Level), coding.Mask(c.Mask))
+	if len(p.Pixel) == h && len(p.Pixel[0]) == w {
+		st.plan = p
+	}
+	st.eyes = []image.Point{{0, 0}}
+	if c.MicroVersion == 0 && c.RectVersion == 0 {
+		st.eyes = append(st.eyes, image.Point{w - 7, 0}, image.Point{0, h - 7})
+		st.outer, st.inner = st.s.FinderOuter, st.s.FinderInner
+	}
+	return st
+}
+
+// inEye reports whether the module (x, y) is in a finder pattern
+// and returns the top left corner of the pattern.
+func (st *styler) inEye(x, y int) (image.Point, bool) {
+	for _, e := range st.eyes {
+		if e.X <= x && x < e.X+7 && e.Y <= y && y < e.Y+7 {
+			return e, true
+		}
+	}
+	return image.Point{}, false
+}
+
+// module returns the shape of the dark module at (x, y),
+// false if it is light or part of a finder pattern.
+func (st *styler) module(x, y int) (roundRect, bool) {
+	if !st.c.IsBlack(x, y) {
+		return roundRect{}, false
+	}
+	if _, ok := st.inEye(x, y); ok {
+		return roundRect{}, false
+	}
+	s := st.s.Module
+	if st.plan != nil {
+		switch st.plan.Pixel[y][x].Role() {
+		case coding.Position, coding.Alignment, coding.Timing:
+			s = ShapeSquare
+		}
+	}
+	rr := shapeRect(s, float64(x), float64(y), 1)
+	if s == ShapeLiquid {
+		// A corner is rounded when neither module next to it is dark.
+		dark := func(dx, dy int) bool { return st.c.IsBlack(x+dx, y+dy) }
+		sides := [4][2][2]int{
+			{{-1, 0}, {0, -1}},
+			{{1, 0}, {0, -1}},
+			{{1, 0}, {0, 1}},
+			{{-1, 0}, {0, 1}},
+		}
+		for i, sd := range sides {
+			rr.r[i] = 0
+			if !dark(sd[0][0], sd[0][1]) && !dark(sd[1][0], sd[1][1]) {
+				rr.r[i] = 0.5
+			}
+		}
+	}
+	return rr, true
+}
+
+// eye returns the outer ring of the finder pattern at e,
+// its hole, and its inner eye.
+func (st *styler) eye(e image.Point) (outer, hole, inner roundRect) {
+	x, y := float64(e.X), float64(e.Y)
+	outer = shapeRect(st.outer, x, y, 7)
+	hole = roundRect{x + 1, y + 1, 5, 5, outer.r}
+	for i := range hole.r {
+		hole.r[i] = math.Max(hole.r[i]-1, 0)
+	}
+	inner = shapeRect(st.inner, x+2, y+2, 3)
+	return outer, hole, inner
+}
+
+// colors returns the colors of the modules, of the outer rings
+// and of the inner eyes of the finder patterns, and of the background.
+func (st *styler) colors() (fg, outer, inner, bg color.NRGBA) {
+	fg, bg = st.c.colors()
+	outer, inner = fg, fg
+	if st.s.FinderOuterColor != nil {
+		outer = color.NRGBAModel.Convert(st.s.FinderOuterColor).(color.NRGBA)
+	}
+	if st.s.FinderInnerColor != nil {
+		inner = color.NRGBAModel.Convert(st.s.FinderInnerColor).(color.NRGBA)
+	}
+	return fg, outer, inner, bg
+}
+
+// StyledImage returns an Image displaying the code drawn in style s,
+// with Scale image pixels per module. A nil s draws plain squares.
+func (c *Code) StyledImage(s *Style) image.Image {
+	st := newStyler(c, s)
+	m := &styledImage{styler: st}
+	m.fg, m.outer, m.inner, m.bg = st.colors()
+	return m
+}
+
+// styledImage implements image.Image.
+type styledImage struct {
+	*styler
+	fg, outer, inner, bg color.NRGBA
+}
+
+func (m *styledImage) Bounds() image.Rectangle {
+	return (&codeImage{Code: m.c}).Bounds()
+}
+
+func (m *styledImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (m *styledImage) At(x, y int) color.Color {
+	// The center of the image pixel, in modules.
+	scale := float64(m.c.Scale)
+	px := (float64(x)+0.5)/scale - float64(m.c.QuietZone)
+	py := (float64(y)+0.5)/scale - float64(m.c.QuietZone)
+	mx, my := int(math.Floor(px)), int(math.Floor(py))
+
+	if e, ok := m.inEye(mx, my); ok {
+		outer, hole, inner := m.eye(e)
+		switch {
+		case inner.contains(px, py):
+			return m.inner
+		case outer.contains(px, py) && !hole.contains(px, py):
+			return m.outer
+		}
+		return m.bg
+	}
+	if rr, ok := m.module(mx, my); ok && rr.contains(px, py) {
+		return m.fg
+	}
+	return m.bg
+}
+
+// StyledSVG returns an SVG image displaying the code drawn in style s,
+// as SVG does with opts. A nil s draws plain squares.
+func (c *Code) StyledSVG(s *Style, opts ...SVGOption) []byte {
+	o := newSVGOptions(c, opts)
+	var w svgWriter
+	return w.encodeStyled(newStyler(c, s), o)
+}
@@ -0,0 +1,258 @@
+package qrcode
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStyledImage(t *testing.T) {
+	const text = "https://github.com/cristalhq/qrcode"
+	fg := WithColors(color.NRGBA{0, 0, 0x60, 0xFF}, nil)
+	codes := []struct {
+		name   string
+		encode func() (*Code, error)
+	}{
+		{"QR", func() (*Code, error) { return EncodeWithOptions(text, M, fg) }},
+		{"Micro QR", func() (*Code, error) { return EncodeMicro("HELLO", L, fg) }},
+		{"rMQR", func() (*Code, error) { return EncodeRect(text, M, fg) }},
+	}
+	styles := []*Style{
+		nil,
+		{Module: ShapeCircle, FinderOuter: ShapeCircle, FinderInner: ShapeCircle},
+		{Module: ShapeRounded, FinderOuter: ShapeRounded, FinderInner: ShapeSquare},
+		{Module: ShapeLiquid, FinderOuter: ShapeRounded, FinderInner: ShapeCircle},
+	}
+	for _, tc := range codes {
+		for _, s := range styles {
+			c, err := tc.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Scale = 10
+			want := text
+			if c.MicroVersion != 0 {
+				want = "HELLO"
+			}
+			r, err := Decode(c.StyledImage(s))
+			if err != nil {
+				t.Fatalf("%s %+v: %v", tc.name, s, err)
+			}
+			if r.Text != want {
+				t.Errorf("%s %+v: have %q", tc.name, s, r.Text)
+			}
+		}
+	}
+}
+
+func TestStyledImageColors(t *testing.T) {
+	c, err := Encode("eyes", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 10
+	red, blue := color.NRGBA{0xFF, 0, 0, 0xFF}, color.NRGBA{0, 0, 0xFF, 0xFF}
+	m := c.StyledImage(&Style{
+		FinderOuter:      ShapeCircle,
+		FinderOuterColor: red,
+		FinderInnerColor: blue,
+	})
+	at := func(x, y float64) color.Color {
+		q := float64(c.QuietZone)
+		return m.At(int((x+q)*10), int((y+q)*10))
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want color.Color
+	}{
+		{3.5, 0.5, red},        // top of the ring
+		{0.1, 0.1, whiteColor}, // outside of the round ring
+		{3.5, 1.5, whiteColor}, // in the hole
+		{3.5, 3.5, blue},       // in the eye
+		{float64(c.Size) - 3.5, 3.5, blue},
+		{6.5, 8.5, color.Black}, // timing pattern
+	} {
+		if !sameColor(at(tc.x, tc.y), tc.want) {
+			t.Errorf("%v,%v: have %v want %v", tc.x, tc.y, at(tc.x, tc.y), tc.want)
+		}
+	}
+}
+
+func TestRoundRect(t *testing.T) {
+	dot := shapeRect(ShapeCircle, 2, 3, 1)
+	for _, tc := range []struct {
+		x, y float64
+		want bool
+	}{
+		{2.5, 3.5, true},
+		{2.05, 3.5, true},
+		{2.1, 3.1, false},
+		{2.9, 3.9, false},
+		{3.5, 3.5, false},
+	} {
+		if have := dot.contains(tc.x, tc.y); have != tc.want {
+			t.Errorf("%v,%v: have %v want %v", tc.x, tc.y, have, tc.want)
+		}
+	}
+}
+
+func TestStyledSVG(t *testing.T) {
+	c, err := EncodeMicro("styled", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := c.StyledSVG(&Style{
+		Module:           ShapeCircle,
+		FinderOuter:      ShapeRounded,
+		FinderInner:      ShapeCircle,
+		FinderInnerColor: color.NRGBA{0xFF, 0, 0, 0xFF},
+	}, WithSVGColors(nil, color.White))
+	var doc svgDoc
+	if err := xml.Unmarshal(svg, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 3 {
+		t.Fatalf("have %d paths want 3", len(doc.Paths))
+	}
+	// Each dark module outside of the finder is a shape of its own.
+	n := 0
+	w, h := c.dims()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if c.IsBlack(x, y) && (x >= 7 || y >= 7) {
+				n++
+			}
+		}
+	}
+	for i, want := range []struct {
+		fill   string
+		shapes int
+	}{{"#000000", n}, {"#000000", 2}, {"#ff0000", 1}} {
+		p := doc.Paths[i]
+		if p.Fill != want.fill || strings.Count(p.D, "Z") != want.shapes {
+			t.Errorf("path %d: have fill %s and %d shapes want %s and %d",
+				i, p.Fill, strings.Count(p.D, "Z"), want.fill, want.shapes)
+		}
+	}
+
+	r, err := Decode(rasterSVG(t, &doc, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "styled" {
+		t.Errorf("have %q", r.Text)
+	}
+}
+
+type svgDoc struct {
+	ViewBox string `xml:"viewBox,attr"`
+	Paths   []struct {
+		Fill string `xml:"fill,attr"`
+		D    string `xml:"d,attr"`
+	} `xml:"path"`
+}
+
+// rasterSVG draws the paths of an SVG image written by StyledSVG over
+// a white background, with scale pixels per unit of its view box.
+// Each path is filled with the even-odd rule, its arcs flattened.
+func rasterSVG(t *testing.T, doc *svgDoc, scale int) image.Image {
+	var w, h int
+	if _, err := fmt.Sscanf(doc.ViewBox, "0 0 %d %d", &w, &h); err != nil {
+		t.Fatal(err)
+	}
+	m := image.NewNRGBA(image.Rect(0, 0, w*scale, h*scale))
+	for i := range m.Pix {
+		m.Pix[i] = 0xFF
+	}
+	for _, p := range doc.Paths {
+		fill := color.NRGBA{A: 0xFF}
+		if _, err := fmt.Sscanf(p.Fill, "#%02x%02x%02x", &fill.R, &fill.G, &fill.B); err != nil {
+			t.Fatal(err)
+		}
+		polys := flattenPath(t, p.D)
+		for y := 0; y < h*scale; y++ {
+			for x := 0; x < w*scale; x++ {
+				px, py := (float64(x)+0.5)/float64(scale), (float64(y)+0.5)/float64(scale)
+				in := false
+				for _, poly := range polys {
+					for i, a := range poly {
+						b := poly[(i+1)%len(poly)]
+						if (a[1] > py) != (b[1] > py) && px < a[0]+(py-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+							in = !in
+						}
+					}
+				}
+				if in {
+					m.SetNRGBA(x, y, fill)
+				}
+			}
+		}
+	}
+	return m
+}
+
+// flattenPath returns the closed subpaths of the path data d, made of
+// absolute M, H, V, Z and quarter circle A commands, as polygons.
+func flattenPath(t *testing.T, d string) [][][2]float64 {
+	for _, cmd := range "MHVAZ" {
+		d = strings.ReplaceAll(d, string(cmd), " "+string(cmd)+" ")
+	}
+	fields := strings.Fields(d)
+	num := func(i int) float64 {
+		f, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	var polys [][][2]float64
+	var poly [][2]float64
+	var cur [2]float64
+	for i := 0; i < len(fields); {
+		switch fields[i] {
+		case "M":
+			cur = [2]float64{num(i + 1), num(i + 2)}
+			poly = [][2]float64{cur}
+			i += 3
+		case "H":
+			cur[0] = num(i + 1)
+			poly = append(poly, cur)
+			i += 2
+		case "V":
+			cur[1] = num(i + 1)
+			poly = append(poly, cur)
+			i += 2
+		case "A":
+			// A clockwise quarter circle: the center is the corner of
+			// the box of the arc from which the end is a quarter turn
+			// of the start.
+			r, end := num(i+1), [2]float64{num(i + 6), num(i + 7)}
+			c := [2]float64{cur[0], end[1]}
+			if math.Abs(-(cur[1]-c[1])-(end[0]-c[0])) > 1e-9 {
+				c = [2]float64{end[0], cur[1]}
+			}
+			a0 := math.Atan2(cur[1]-c[1], cur[0]-c[0])
+			for k := 1; k <= 8; k++ {
+				a := a0 + math.Pi/2*float64(k)/8
+				poly = append(poly, [2]float64{c[0] + r*math.Cos(a), c[1] + r*math.Sin(a)})
+			}
+			cur = end
+			i += 8
+		case "Z":
+			polys = append(polys, poly)
+			i++
+		default:
+			t.Fatalf("unexpected %q in path", fields[i])
+		}
+	}
+	return polys
+}
+
+func sameColor(a, b color.Color) bool {
+	return color.NRGBAModel.Convert(a) == color.NRGBAModel.Convert(b)
+}
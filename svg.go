@@ -2,69 +2,246 @@ package qrcode
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
+	"image/color"
+	"strconv"
 )
 
-func (c *Code) SVG() []byte {
+// SVG returns an SVG image displaying the code, with its quiet zone around it.
+// The view box is in modules and the dark modules are drawn as one path.
+// By default it has the colors and the quiet zone of the code,
+// 10 pixels per module and an XML header; opts change them.
+func (c *Code) SVG(opts ...SVGOption) []byte {
 	var w svgWriter
-	return w.encode(c)
+	return w.encode(c, newSVGOptions(c, opts))
+}
+
+// An SVGOption configures how SVG draws a code.
+type SVGOption func(*svgOptions)
+
+type svgOptions struct {
+	foreground  color.Color // nil for black
+	background  color.Color // nil for none
+	quietZone   int
+	pixelSize   int
+	crispEdges  bool
+	title, desc string // empty for none
+	header      bool
+}
+
+// newSVGOptions applies opts to the defaults of code c.
+func newSVGOptions(c *Code, opts []SVGOption) *svgOptions {
+	o := &svgOptions{
+		foreground: c.Foreground,
+		background: c.Background,
+		quietZone:  c.QuietZone,
+		pixelSize:  10,
+		header:     true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSVGColors sets the colors of the dark modules and of the background.
+// A nil foreground is black and a nil background is not drawn.
+func WithSVGColors(foreground, background color.Color) SVGOption {
+	return func(o *svgOptions) {
+		o.foreground = foreground
+		o.background = background
+	}
+}
+
+// WithSVGQuietZone sets the width of the border around the code, in modules.
+// A negative width is ignored.
+func WithSVGQuietZone(modules int) SVGOption {
+	return func(o *svgOptions) {
+		if modules >= 0 {
+			o.quietZone = modules
+		}
+	}
+}
+
+// WithSVGPixelSize sets the width and height of a module in pixels.
+// A size that is not positive is ignored.
+func WithSVGPixelSize(pixels int) SVGOption {
+	return func(o *svgOptions) {
+		if pixels > 0 {
+			o.pixelSize = pixels
+		}
+	}
+}
+
+// WithSVGCrispEdges asks renderers not to antialias the edges of the modules.
+func WithSVGCrispEdges() SVGOption {
+	return func(o *svgOptions) {
+		o.crispEdges = true
+	}
+}
+
+// WithSVGTitle sets the title and description elements of the image,
+// none of them when empty.
+func WithSVGTitle(title, desc string) SVGOption {
+	return func(o *svgOptions) {
+		o.title = title
+		o.desc = desc
+	}
+}
+
+// WithoutSVGHeader leaves out the XML header, for SVG inlined in HTML.
+func WithoutSVGHeader() SVGOption {
+	return func(o *svgOptions) {
+		o.header = false
+	}
 }
 
 type svgWriter struct {
 	bytes.Buffer
 }
 
-func (wr *svgWriter) encode(code *Code) []byte {
-	size := code.Size
+func (wr *svgWriter) encode(code *Code, o *svgOptions) []byte {
+	w, h := code.dims()
+	q := o.quietZone
 
 	wr.Reset()
-	wr.start()
-
-	blockSize := 10
-	currY := 0
-	for x := 0; x < size; x++ {
-		currX := 0
-		for y := 0; y < size; y++ {
-			if code.IsBlack(x, y) {
-				wr.writeRect(currX, currY, blockSize, blockSize)
+	wr.start(w+2*q, h+2*q, o)
+
+	// Each run of dark modules in a row is a rectangle of the path.
+	wr.WriteString("<path")
+	wr.writeFill(o.foreground)
+	wr.WriteString(` d="`)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; {
+			if !code.IsBlack(x, y) {
+				x++
+				continue
 			}
-			currX += blockSize
+			n := 1
+			for x+n < w && code.IsBlack(x+n, y) {
+				n++
+			}
+			fmt.Fprintf(&wr.Buffer, "M%d %dh%dv1h-%dz", x+q, y+q, n, n)
+			x += n
 		}
-		currY += blockSize
 	}
+	wr.WriteString(`"/>`)
+	wr.WriteByte('\n')
 
 	wr.end()
 	return wr.Bytes()
 }
 
-func (wr *svgWriter) start() {
-	wr.WriteString(svgHeader)
-	fmt.Fprintf(&wr.Buffer, svgStart, 220, 220) // TODO(cristaloleg): magic but works.
+func (wr *svgWriter) start(width, height int, o *svgOptions) {
+	if o.header {
+		wr.WriteString(svgHeader)
+	}
+	fmt.Fprintf(&wr.Buffer, svgStart, width*o.pixelSize, height*o.pixelSize, width, height)
+	if o.crispEdges {
+		wr.WriteString(` shape-rendering="crispEdges"`)
+	}
+	wr.WriteString(">\n")
+	wr.writeElement("title", o.title)
+	wr.writeElement("desc", o.desc)
+	if o.background != nil {
+		wr.WriteString(`<rect width="100%" height="100%"`)
+		wr.writeFill(o.background)
+		wr.WriteString("/>\n")
+	}
+}
+
+// encodeStyled draws the code of st with a path for the modules,
+// one for the outer rings of the finder patterns and one for their eyes.
+func (wr *svgWriter) encodeStyled(st *styler, o *svgOptions) []byte {
+	w, h := st.c.dims()
+	q := float64(o.quietZone)
+
+	wr.Reset()
+	wr.start(w+2*o.quietZone, h+2*o.quietZone, o)
+
+	shift := func(rr roundRect) roundRect {
+		rr.x += q
+		rr.y += q
+		return rr
+	}
+	wr.WriteString("<path")
+	wr.writeFill(o.foreground)
+	wr.WriteString(` d="`)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if rr, ok := st.module(x, y); ok {
+				rr = shift(rr)
+				rr.path(&wr.Buffer)
+			}
+		}
+	}
+	wr.WriteString("\"/>\n")
+
+	outerColor, innerColor := st.s.FinderOuterColor, st.s.FinderInnerColor
+	if outerColor == nil {
+		outerColor = o.foreground
+	}
+	if innerColor == nil {
+		innerColor = o.foreground
+	}
+	wr.WriteString("<path")
+	wr.writeFill(outerColor)
+	wr.WriteString(` fill-rule="evenodd" d="`)
+	for _, e := range st.eyes {
+		outer, hole, _ := st.eye(e)
+		outer, hole = shift(outer), shift(hole)
+		outer.path(&wr.Buffer)
+		hole.path(&wr.Buffer)
+	}
+	wr.WriteString("\"/>\n<path")
+	wr.writeFill(innerColor)
+	wr.WriteString(` d="`)
+	for _, e := range st.eyes {
+		_, _, inner := st.eye(e)
+		inner = shift(inner)
+		inner.path(&wr.Buffer)
+	}
+	wr.WriteString("\"/>\n")
+
+	wr.end()
+	return wr.Bytes()
 }
 
 func (wr *svgWriter) end() {
 	wr.WriteString(svgEnd)
 }
 
-func (wr *svgWriter) writeRect(x, y, w, h int) {
-	wr.WriteString("<rect ")
-	wr.WriteString(dims(x, y, w, h))
-	wr.WriteString(` style="fill:black;stroke:none" />`)
-	wr.WriteByte('\n')
+// writeElement writes the element name with the text s, if any.
+func (wr *svgWriter) writeElement(name, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(&wr.Buffer, "<%s>", name)
+	xml.EscapeText(&wr.Buffer, []byte(s))
+	fmt.Fprintf(&wr.Buffer, "</%s>\n", name)
 }
 
-func dims(x int, y int, w int, h int) string {
-	return fmt.Sprintf(`x="%d" y="%d" width="%d" height="%d"`, x, y, w, h)
+// writeFill writes the fill attributes of the color c, black if nil.
+func (wr *svgWriter) writeFill(c color.Color) {
+	if c == nil {
+		c = color.Black
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fmt.Fprintf(&wr.Buffer, ` fill="#%02x%02x%02x"`, n.R, n.G, n.B)
+	if n.A != 0xFF {
+		wr.WriteString(` fill-opacity="`)
+		wr.WriteString(strconv.FormatFloat(float64(n.A)/0xFF, 'f', 3, 64))
+		wr.WriteByte('"')
+	}
 }
 
 const (
 	svgHeader = `<?xml version="1.0"?><!-- Generated by cristalhq -->
 `
 
-	svgStart = `<svg width="%d" height="%d"
-     xmlns="http://www.w3.org/2000/svg"
-     xmlns:xlink="http://www.w3.org/1999/xlink">
-`
+	svgStart = `<svg width="%d" height="%d" viewBox="0 0 %d %d"
+     xmlns="http://www.w3.org/2000/svg"`
 
 	svgEnd = `</svg>`
 )
@@ -0,0 +1,95 @@
+package qrcode
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	for _, q := range []int{0, 4} {
+		c, err := EncodeWithOptions("svg", L, WithQuietZone(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		svg := string(c.SVG())
+		if !strings.HasPrefix(svg, "<?xml") {
+			t.Errorf("quiet zone %d: have no XML header", q)
+		}
+		size := c.Size + 2*q
+		if !strings.Contains(svg, fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d"`, size*10, size*10, size, size)) {
+			t.Errorf("quiet zone %d: have no %dx%d canvas", q, size, size)
+		}
+		checkSVG(t, c, q, svg)
+	}
+}
+
+func TestSVGOptions(t *testing.T) {
+	c, err := EncodeRect("svg options", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(c.SVG(
+		WithSVGColors(color.NRGBA{0x12, 0x34, 0x56, 0x80}, color.White),
+		WithSVGQuietZone(1),
+		WithSVGPixelSize(3),
+		WithSVGCrispEdges(),
+		WithSVGTitle("a <title>", "R & D"),
+		WithoutSVGHeader(),
+	))
+	w, h := c.dims()
+	for _, want := range []string{
+		fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d"`, (w+2)*3, (h+2)*3, w+2, h+2),
+		` shape-rendering="crispEdges">`,
+		"<title>a &lt;title&gt;</title>",
+		"<desc>R &amp; D</desc>",
+		`<rect width="100%" height="100%" fill="#ffffff"/>`,
+		`<path fill="#123456" fill-opacity="0.502" d="`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("have no %s in\n%.500s", want, svg)
+		}
+	}
+	if !strings.HasPrefix(svg, "<svg") {
+		t.Error("have an XML header")
+	}
+	checkSVG(t, c, 1, svg)
+}
+
+// checkSVG checks that svg is well formed and that its path
+// covers the dark modules of c with a quiet zone of q modules.
+func checkSVG(t *testing.T, c *Code, q int, svg string) {
+	t.Helper()
+	var doc struct {
+		Path struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal([]byte(svg), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	w, h := c.dims()
+	dark := make(map[[2]int]bool)
+	for _, run := range strings.Split(doc.Path.D, "z") {
+		if run == "" {
+			continue
+		}
+		var x, y, n, back int
+		if _, err := fmt.Sscanf(run, "M%d %dh%dv1h-%d", &x, &y, &n, &back); err != nil || n != back {
+			t.Fatalf("have run %q", run)
+		}
+		for i := 0; i < n; i++ {
+			dark[[2]int{x + i - q, y - q}] = true
+		}
+	}
+	for y := -q; y < h+q; y++ {
+		for x := -q; x < w+q; x++ {
+			if dark[[2]int{x, y}] != c.IsBlack(x, y) {
+				t.Fatalf("quiet zone %d: module %d,%d differs", q, x, y)
+			}
+		}
+	}
+}